@@ -0,0 +1,114 @@
+package mlog
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// apiStatus returns the status of the first Kubernetes API status error in the chain, i.e. one returned by client-go.
+func apiStatus(err error) (metav1.Status, bool) {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return metav1.Status{}, false
+	}
+	if v := reflect.ValueOf(status); v.Kind() == reflect.Ptr && v.IsNil() {
+		return metav1.Status{}, false // calling Status would panic
+	}
+	return status.Status(), true
+}
+
+// addAPIStatus adds the code, reason and details of the status under keys that start with key.
+func addAPIStatus(enc zapcore.ObjectEncoder, key string, status metav1.Status) error {
+	if status.Code != 0 {
+		enc.AddInt32(key+"Code", status.Code)
+	}
+	if len(status.Reason) > 0 {
+		enc.AddString(key+"Reason", string(status.Reason))
+	}
+	if status.Details != nil {
+		return enc.AddObject(key+"Details", statusDetails(*status.Details))
+	}
+	return nil
+}
+
+// apiStatusKeysAndValues is addAPIStatus for the text format, which cannot encode nested objects.
+func apiStatusKeysAndValues(key string, status metav1.Status) []interface{} {
+	var out []interface{}
+	if status.Code != 0 {
+		out = append(out, key+"Code", status.Code)
+	}
+	if len(status.Reason) > 0 {
+		out = append(out, key+"Reason", string(status.Reason))
+	}
+	if d := status.Details; d != nil {
+		for _, kv := range []struct{ key, value string }{{"group", d.Group}, {"kind", d.Kind}, {"name", d.Name}} {
+			if len(kv.value) > 0 {
+				out = append(out, key+"Details."+kv.key, kv.value)
+			}
+		}
+		if len(d.Causes) > 0 {
+			causes := make([]string, 0, len(d.Causes))
+			for _, cause := range d.Causes {
+				causes = append(causes, causeMessage(cause))
+			}
+			out = append(out, key+"Details.causes", strings.Join(causes, "; "))
+		}
+	}
+	return out
+}
+
+var _ zapcore.ObjectMarshaler = statusDetails{}
+
+type statusDetails metav1.StatusDetails
+
+func (d statusDetails) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, kv := range []struct{ key, value string }{{"group", d.Group}, {"kind", d.Kind}, {"name", d.Name}} {
+		if len(kv.value) > 0 {
+			enc.AddString(kv.key, kv.value)
+		}
+	}
+	if len(d.Causes) > 0 {
+		return enc.AddArray("causes", statusCauses(d.Causes))
+	}
+	return nil
+}
+
+var _ zapcore.ArrayMarshaler = statusCauses{}
+
+type statusCauses []metav1.StatusCause
+
+func (c statusCauses) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, cause := range c {
+		if err := enc.AppendObject(statusCause(cause)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ zapcore.ObjectMarshaler = statusCause{}
+
+type statusCause metav1.StatusCause
+
+func (c statusCause) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	// use the same keys as the JSON encoding of metav1.StatusCause
+	for _, kv := range []struct{ key, value string }{{"reason", string(c.Type)}, {"message", c.Message}, {"field", c.Field}} {
+		if len(kv.value) > 0 {
+			enc.AddString(kv.key, kv.value)
+		}
+	}
+	return nil
+}
+
+func causeMessage(cause metav1.StatusCause) string {
+	if len(cause.Field) == 0 {
+		return cause.Message
+	}
+	return cause.Field + ": " + cause.Message
+}
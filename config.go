@@ -3,14 +3,15 @@ package mlog
 import (
 	"context"
 	"encoding/json"
+	"math"
 	"strconv"
-	"time"
 
 	"go.uber.org/zap/zapcore"
 
-	"k8s.io/apimachinery/pkg/util/wait"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/component-base/logs"
 	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/textlogger"
 )
 
 type LogFormat string
@@ -23,7 +24,7 @@ func (l *LogFormat) UnmarshalJSON(b []byte) error {
 		*l = FormatText
 	// there is no "cli" case because it is not a supported option via server config
 	default:
-		return errInvalidLogFormat
+		return ErrInvalidLogFormat
 	}
 	return nil
 }
@@ -33,8 +34,9 @@ const (
 	FormatText LogFormat = "text" // Deprecated
 	FormatCLI  LogFormat = "cli"  // only meant to be used by CLI and not server components
 
-	errInvalidLogLevel  = constableError("invalid log level, valid choices are the empty string, info, debug, trace and all")
-	errInvalidLogFormat = constableError("invalid log format, valid choices are the empty string, json and text")
+	ErrInvalidLogLevel   = constableError("invalid log level, valid choices are the empty string, info, debug, trace and all")
+	ErrInvalidLogFormat  = constableError("invalid log format, valid choices are the empty string, json and text")
+	ErrInvalidLogOutputs = constableError("invalid log outputs, file must be unset and the text format is not supported when outputs are set")
 )
 
 var _ json.Unmarshaler = func() *LogFormat {
@@ -45,65 +47,294 @@ var _ json.Unmarshaler = func() *LogFormat {
 type LogSpec struct {
 	Level  LogLevel  `json:"level,omitempty"`
 	Format LogFormat `json:"format,omitempty"`
+	// KlogLevel further restricts Level for logs from libraries that log via klog, such as client-go, i.e. our
+	// code can log at debug while those libraries only log at info.  Like OutputSpec.Level, it cannot make klog
+	// based logs more verbose than Level.  Leaving it unset means that klog based logs use Level, while setting
+	// it to LevelWarning (i.e. the empty string) restricts them to warnings and errors.  Not used by
+	// NewWithConfig since independent loggers are never used by klog.
+	KlogLevel *LogLevel `json:"klogLevel,omitempty"`
+	// LoggerLevels overrides Level for loggers whose name (as built up via WithName) starts with the given prefix.
+	// The most specific prefix wins, i.e. webhook.tokenexchange takes precedence over webhook.
+	// Overrides are not supported by the deprecated text format.
+	LoggerLevels map[string]LogLevel `json:"loggerLevels,omitempty"`
+	// VModule overrides Level (and LoggerLevels) for the call sites that match a pattern, the first match wins.
+	// Like klog's -vmodule, it can only make logs more verbose.  It is not supported by the deprecated text format.
+	// Since patterns also apply to klog based libraries, klog's verbosity is raised to the most verbose pattern.
+	// Those libraries then build all of their logs up to that level, only for the logs that do not match a
+	// pattern to be discarded, thus a pattern at trace or all has a noticeable cost in a kube based process.
+	VModule []VModuleSpec `json:"vmodule,omitempty"`
+	// File configures logging to a file instead of stderr.
+	File *FileSpec `json:"file,omitempty"`
+	// Outputs configures logging to multiple destinations at once.  When set, it replaces the single output
+	// described by Format and File, thus File must be unset and Format must not be text.
+	Outputs []OutputSpec `json:"outputs,omitempty"`
+	// Sampling configures sampling of repetitive logs.  All logs are kept if Sampling is unset.
+	Sampling *SamplingSpec `json:"sampling,omitempty"`
+	// Errors configures additional details that are included when errors are logged.  Only the message of
+	// each error is logged if Errors is unset.
+	Errors *ErrorSpec `json:"errors,omitempty"`
+	// Stacktrace configures which logs include a stack trace, see StacktracePolicy.
+	Stacktrace StacktracePolicy `json:"stacktrace,omitempty"`
+	// StackFilter configures how stack traces are rendered.  Stack traces are rendered as is if StackFilter is unset.
+	StackFilter *StackFilterSpec `json:"stackFilter,omitempty"`
+	// LegacyWarningKey keeps the "warning": true key (warning=true in the text format) that was used to mark
+	// warnings before they were encoded with the warning level.  This is only meant to ease migration.
+	LegacyWarningKey bool `json:"legacyWarningKey,omitempty"`
+	// FlushInterval is how often logs are flushed in the background, defaults to 1m.  Not used by FormatCLI.
+	FlushInterval *metav1.Duration `json:"flushInterval,omitempty"`
+}
+
+// OutputSpec configures a single destination for logs.
+type OutputSpec struct {
+	// Format defaults to json.  The deprecated text format is not supported.  Like the top level Format,
+	// FormatCLI can only be set programmatically.
+	Format LogFormat `json:"format,omitempty"`
+	// Level further restricts the global log level for this output, i.e. an output at info only receives
+	// info logs even if the global level is debug.  It cannot make an output more verbose than the global level.
+	// Leaving it unset means that the output receives all logs that are enabled by the global level, while
+	// setting it to LevelWarning (i.e. the empty string) restricts the output to warnings and errors.
+	Level *LogLevel `json:"level,omitempty"`
+	// File configures logging to a file instead of stderr.
+	File *FileSpec `json:"file,omitempty"`
+}
+
+func (s LogSpec) deepCopy() LogSpec {
+	out := s
+	if s.KlogLevel != nil {
+		klogLevel := *s.KlogLevel
+		out.KlogLevel = &klogLevel
+	}
+	if s.LoggerLevels != nil {
+		out.LoggerLevels = make(map[string]LogLevel, len(s.LoggerLevels))
+		for prefix, level := range s.LoggerLevels {
+			out.LoggerLevels[prefix] = level
+		}
+	}
+	if s.VModule != nil {
+		out.VModule = make([]VModuleSpec, len(s.VModule))
+		copy(out.VModule, s.VModule)
+	}
+	out.File = s.File.deepCopy()
+	if s.Outputs != nil {
+		out.Outputs = make([]OutputSpec, len(s.Outputs))
+		for i, output := range s.Outputs {
+			if output.Level != nil {
+				level := *output.Level
+				output.Level = &level
+			}
+			output.File = output.File.deepCopy()
+			out.Outputs[i] = output
+		}
+	}
+	out.Sampling = s.Sampling.deepCopy()
+	out.Errors = s.Errors.deepCopy()
+	out.StackFilter = s.StackFilter.deepCopy()
+	if s.FlushInterval != nil {
+		flushInterval := *s.FlushInterval
+		out.FlushInterval = &flushInterval
+	}
+	return out
+}
+
+// logOutputs converts the outputs described by the already validated spec into their internal form.
+func (s LogSpec) logOutputs(encoding string) []logOutput {
+	if len(s.Outputs) == 0 {
+		return []logOutput{{encoding: encoding, file: s.File, errors: s.Errors, stacktrace: s.Stacktrace, stackFilter: s.StackFilter, legacyWarningKey: s.LegacyWarningKey}}
+	}
+
+	outputs := make([]logOutput, 0, len(s.Outputs))
+	for _, output := range s.Outputs {
+		out := logOutput{encoding: "json", file: output.File, errors: s.Errors, stacktrace: s.Stacktrace, stackFilter: s.StackFilter, legacyWarningKey: s.LegacyWarningKey}
+		if output.Format == FormatCLI {
+			out.encoding = "console"
+		}
+		if output.Level != nil {
+			out.level = zapcore.Level(-klogLevelForMlogLevel(*output.Level)) // klog levels are inverted when zap handles them
+		}
+		outputs = append(outputs, out)
+	}
+	return outputs
 }
 
 func ValidateAndSetLogLevelAndFormatGlobally(ctx context.Context, spec LogSpec) error {
 	klogLevel := klogLevelForMlogLevel(spec.Level)
 
-	return validateAndSetKlogLevelAndFormatGlobally(ctx, klogLevel, spec.Format, true)
+	return validateAndSetKlogLevelAndFormatGlobally(ctx, klogLevel, spec, true)
 }
 
 // Deprecated
 func ValidateAndSetKlogLevelAndFormatGlobally(ctx context.Context, klogLevel klog.Level, format LogFormat) error {
-	return validateAndSetKlogLevelAndFormatGlobally(ctx, klogLevel, format, false)
+	reportedLevel := klogLevel
+	if reportedLevel > klogLevelAll {
+		reportedLevel = klogLevelAll // prevent overflow of the zap level
+	}
+	spec := LogSpec{
+		Level:  zapLevelToMlogLevel(zapcore.Level(-reportedLevel)), // best effort mapping for reporting purposes
+		Format: format,
+	}
+
+	return validateAndSetKlogLevelAndFormatGlobally(ctx, klogLevel, spec, false)
 }
 
-func validateAndSetKlogLevelAndFormatGlobally(ctx context.Context, klogLevel klog.Level, format LogFormat, warn bool) error {
+func validateAndSetKlogLevelAndFormatGlobally(ctx context.Context, klogLevel klog.Level, spec LogSpec, warn bool) error {
+	if err := spec.validate(nil).err(); err != nil {
+		return err
+	}
+
 	if klogLevel < 0 {
-		return errInvalidLogLevel
+		return ErrInvalidLogLevel // only reachable via the deprecated klog level based API
 	}
 
-	// set the global log levels used by our code and the kube code underneath us
-	if _, err := logs.GlogSetter(strconv.Itoa(int(klogLevel))); err != nil {
-		panic(err) // programmer error
+	levels, err := newLoggerLevels(spec.LoggerLevels)
+	if err != nil {
+		return err
 	}
-	globalLevel.SetLevel(zapcore.Level(-klogLevel)) // klog levels are inverted when zap handles them
 
 	var encoding string
-	switch format {
+	switch spec.Format {
 	case "", FormatJSON:
 		encoding = "json"
+		spec.Format = FormatJSON
 	case FormatCLI:
 		encoding = "console"
 	case FormatText:
 		encoding = "text"
-	default:
-		return errInvalidLogFormat
 	}
 
-	log, flush, err := newLogr(ctx, encoding, klogLevel)
+	outputs := spec.logOutputs(encoding)
+	sampler := spec.Sampling.sampler()
+	cliSampling := spec.Format == FormatCLI && sampler != nil
+	if cliSampling {
+		sampler = nil // the summary of dropped logs is never logged without a lifecycle, see below
+	}
+
+	globalSpecLock.Lock()
+	defer globalSpecLock.Unlock()
+
+	var (
+		l          *loggers
+		textConfig *textlogger.Config
+	)
+	if encoding == "text" {
+		l, textConfig, err = newTextLogr(ctx, klogLevel, outputs[0])
+	} else {
+		l, err = newLogr(ctx, klogLevel, sampler, outputs...)
+	}
 	if err != nil {
 		return err
 	}
 
-	setGlobalLoggers(log, flush)
+	previous := globalLoggers.Load()
+
+	globalKlogSinkLevel.SetLevel(klogSinkLevel(spec.KlogLevel))
+	setVModuleGlobally(spec.VModule)
+	setGlobalLoggers(l)
+	globalTextConfig = textConfig
+	globalSpec = spec.deepCopy()
+
+	// the previous lifecycle performs a final flush of the previous loggers and releases them as it exits,
+	// there is no lifecycle on the CLI or after Shutdown so the previous loggers are released directly
+	if globalLifecycle != nil {
+		stopLifecycleLocked()
+	} else {
+		previous.close()
+	}
+
+	// set the global log levels used by our code and the kube code underneath us
+	setLevelGlobally(klogLevel, levels)
+
+	// now that the levels are set, write any logs that were emitted before the config was applied
+	replayEarlyBufferLocked(l.log)
 
 	//nolint:exhaustive  // the switch above is exhaustive for format already
-	switch format {
+	switch spec.Format {
 	case FormatCLI:
-		return nil // do not spawn go routines on the CLI to allow the CLI to call this more than once
+		if cliSampling {
+			Warning("log.sampling is ignored when log.format is 'cli'")
+		}
+		// do not spawn go routines on the CLI to allow the CLI to call this more than once.
+		// klog's verbosity is still reconciled whenever levels are applied, see updateLevelGlobally.
+		return nil
 	case FormatText:
 		if warn {
 			Warning("setting log.format to 'text' is deprecated - this option will be removed in a future release")
 		}
+		if len(levels) > 0 {
+			Warning("log.loggerLevels is ignored when log.format is 'text'")
+		}
+		if sampler != nil {
+			Warning("log.sampling is ignored when log.format is 'text'")
+			sampler = nil
+		}
+		if spec.Errors.textIgnored() {
+			Warning("log.errors only supports apiStatus when log.format is 'text'")
+		}
 	}
 
-	// do spawn go routines on the server
-	go wait.UntilWithContext(ctx, func(_ context.Context) { flush() }, time.Minute)
-	go func() {
-		<-ctx.Done()
-		flush() // best effort flush before shutdown as this is not coordinated with a wait group
-	}()
+	// do spawn a go routine on the server, use Shutdown to stop it and perform a final flush
+	flushInterval := defaultFlushInterval
+	if spec.FlushInterval != nil && spec.FlushInterval.Duration > 0 {
+		flushInterval = spec.FlushInterval.Duration
+	}
+	globalLifecycle = startLifecycle(ctx, flushInterval, l, sampler, reconcileKlogLevel)
 
 	return nil
 }
+
+// setLevelGlobally updates the configured log levels without rebuilding any loggers.
+// the caller must hold globalSpecLock.
+func setLevelGlobally(klogLevel klog.Level, levels loggerLevels) {
+	globalKlogLevel = klogLevel
+	globalLoggerLevels.Store(&levels)
+
+	updateLevelGlobally()
+}
+
+// updateLevelGlobally applies the most verbose of the configured log level and any active escalations.
+// the caller must hold globalSpecLock.
+func updateLevelGlobally() {
+	klogLevel := effectiveKlogLevel()
+
+	// changes to klog's verbosity that were not made via mlog are about to be overwritten, see reconcileKlogLevelLocked
+	if actual := klogVerbosity(); actual != globalKlogVerbosity {
+		Warning("klog verbosity was changed outside of mlog and has been reset, use the log level config instead",
+			"klogLevel", actual, "expectedKlogLevel", globalKlogVerbosity)
+	}
+
+	verbosity := klogVerbosityFor(klogLevel)
+	if _, err := logs.GlogSetter(strconv.Itoa(int(verbosity))); err != nil {
+		panic(err) // programmer error
+	}
+	globalKlogVerbosity = verbosity
+	globalLevel.SetLevel(zapcore.Level(-klogLevel)) // klog levels are inverted when zap handles them
+
+	if globalTextConfig != nil {
+		if err := globalTextConfig.V().Set(strconv.Itoa(int(klogLevel))); err != nil {
+			panic(err) // programmer error
+		}
+	}
+}
+
+// effectiveKlogLevel returns the most verbose of the configured log level and any active escalations.
+// it is capped at math.MaxInt8 to prevent overflow of the zap level, which also keeps klog's verbosity
+// within what klogVerbosity can detect.  the caller must hold globalSpecLock.
+func effectiveKlogLevel() klog.Level {
+	klogLevel := globalKlogLevel
+	for _, e := range globalEscalations {
+		if e.klogLevel > klogLevel {
+			klogLevel = e.klogLevel
+		}
+	}
+	if klogLevel > math.MaxInt8 {
+		return math.MaxInt8
+	}
+	return klogLevel
+}
+
+// reconcileKlogLevel is reconcileKlogLevelLocked for callers that do not hold globalSpecLock.
+func reconcileKlogLevel() {
+	globalSpecLock.Lock()
+	defer globalSpecLock.Unlock()
+
+	reconcileKlogLevelLocked()
+}
@@ -4,10 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,7 +44,7 @@ func TestFormat(t *testing.T) {
 	wd, err := os.Getwd()
 	require.NoError(t, err)
 
-	const startLogLine = 44 // make this match the current line number
+	const startLogLine = 47 // make this match the current line number
 
 	Info("hello", "happy", "day", "duration", time.Hour+time.Minute)
 	require.True(t, scanner.Scan())
@@ -56,7 +59,7 @@ func TestFormat(t *testing.T) {
   "duration": "1h1m0s"
 }`, wd, startLogLine+2), scanner.Text())
 
-	Logr().WithName("burrito").Error(errInvalidLogLevel, "wee", "a", "b")
+	Logr().WithName("burrito").Error(ErrInvalidLogLevel, "wee", "a", "b")
 	require.True(t, scanner.Scan())
 	require.NoError(t, scanner.Err())
 	require.JSONEq(t, fmt.Sprintf(`
@@ -81,19 +84,19 @@ func TestFormat(t *testing.T) {
   "message": "hey"
 }`, wd, startLogLine+2+13+14), scanner.Text())
 
-	Warning("bad stuff") // note that this sets the custom warning key because it is via mlog
+	Warning("bad stuff") // note that this uses the warning level because it is via mlog
 	require.True(t, scanner.Scan())
 	require.NoError(t, scanner.Err())
 	require.JSONEq(t, fmt.Sprintf(`
 {
-  "level": "info",
+  "level": "warning",
   "timestamp": "2022-11-21T23:37:26.953313Z",
   "caller": "%s/config_test.go:%d$mlog.TestFormat",
-  "message": "bad stuff",
-  "warning": true
+  "message": "bad stuff"
 }`, wd, startLogLine+2+13+14+11), scanner.Text())
+	require.NotContains(t, scanner.Text(), `"warning":true`, "the legacy key is only used when requested")
 
-	func() { DebugErr("something happened", errInvalidLogFormat, "an", "item") }()
+	func() { DebugErr("something happened", ErrInvalidLogFormat, "an", "item") }()
 	require.True(t, scanner.Scan())
 	require.NoError(t, scanner.Err())
 	require.JSONEq(t, fmt.Sprintf(`
@@ -145,13 +148,13 @@ testing.tRunner
 	err = ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelDebug, Format: FormatCLI})
 	require.NoError(t, err)
 
-	DebugErr("something happened", errInvalidLogFormat, "an", "item")
+	DebugErr("something happened", ErrInvalidLogFormat, "an", "item")
 	require.True(t, scanner.Scan())
 	require.NoError(t, scanner.Err())
 	require.Equal(t, fmt.Sprintf(nowStr+`  mlog/config_test.go:%d  something happened  {"error": "invalid log format, valid choices are the empty string, json and text", "an": "item"}`,
 		startLogLine+2+13+14+11+12+24+28), scanner.Text())
 
-	Logr().WithName("burrito").Error(errInvalidLogLevel, "wee", "a", "b", "slightly less than a year", 363*24*time.Hour, "slightly more than 2 years", 2*367*24*time.Hour)
+	Logr().WithName("burrito").Error(ErrInvalidLogLevel, "wee", "a", "b", "slightly less than a year", 363*24*time.Hour, "slightly more than 2 years", 2*367*24*time.Hour)
 	require.True(t, scanner.Scan())
 	require.NoError(t, scanner.Err())
 	require.Equal(t, fmt.Sprintf(nowStr+`  burrito  mlog/config_test.go:%d  wee  {"a": "b", "slightly less than a year": "363d", "slightly more than 2 years": "2y4d", "error": "invalid log level, valid choices are the empty string, info, debug, trace and all"}`,
@@ -174,7 +177,7 @@ testing.tRunner
 	// check for the deprecation warning
 	require.True(t, scanner.Scan())
 	require.NoError(t, scanner.Err())
-	require.Equal(t, fmt.Sprintf(`I1121 23:37:26.953313%8d config.go:97] "setting log.format to 'text' is deprecated - this option will be removed in a future release" warning=true`,
+	require.Equal(t, fmt.Sprintf(`W1121 23:37:26.953313%8d config.go:260] "setting log.format to 'text' is deprecated - this option will be removed in a future release"`,
 		pid), scanner.Text())
 
 	Debug("what is happening", "does klog", "work?")
@@ -220,7 +223,7 @@ testing.tRunner
 	old2.Info("info")
 	require.True(t, scanner.Scan())
 	require.NoError(t, scanner.Err())
-	require.Equal(t, fmt.Sprintf(`I1121 23:37:26.953313%8d config_test.go:%d] "created before mode change: warn" is="old" i am="old1" warning=true`,
+	require.Equal(t, fmt.Sprintf(`W1121 23:37:26.953313%8d config_test.go:%d] "created before mode change: warn" is="old" i am="old1"`,
 		pid, startLogLine+2+13+14+11+12+24+28+6+26+6+6+7+1+10+9), scanner.Text())
 	require.True(t, scanner.Scan())
 	require.NoError(t, scanner.Err())
@@ -334,7 +337,7 @@ func TestValidateAndSetLogLevelGlobally(t *testing.T) {
 			name:      "invalid level",
 			level:     "panda",
 			wantLevel: originalLogLevel,
-			wantErr:   errInvalidLogLevel.Error(),
+			wantErr:   `level: Unsupported value: "panda": supported values: "", "info", "debug", "trace", "all"`,
 		},
 	}
 	for _, tt := range tests {
@@ -366,6 +369,143 @@ func TestValidateAndSetLogLevelGlobally(t *testing.T) {
 	require.Equal(t, originalLogLevel, getKlogLevel())
 }
 
+func TestLoggerLevels(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf bytes.Buffer
+
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	err := ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{
+		Level: LevelInfo,
+		LoggerLevels: map[string]LogLevel{
+			"webhook.tokenexchange": LevelDebug,
+			"leader":                LevelWarning,
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{}))
+	})
+
+	webhook := New().WithName("webhook")
+	webhook.Info("webhook info")
+	webhook.Debug("webhook debug")
+	webhook.WithName("tokenexchange").Debug("token exchange debug")
+	webhook.WithName("tokenexchange").WithName("cache").Trace("token exchange cache trace")
+	New().WithName("webhook.tokenexchanger").Debug("different webhook debug")
+	WithName("leader").Info("leader info")
+	WithName("leader").WithName("election").Warning("leader election warning")
+	Info("global info")
+	Debug("global debug")
+
+	var messages []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line struct {
+			Message string `json:"message"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		messages = append(messages, line.Message)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Equal(t, []string{
+		"webhook info",
+		"token exchange debug",
+		"leader election warning",
+		"global info",
+	}, messages)
+
+	require.True(t, Enabled(LevelInfo))
+	require.False(t, Enabled(LevelDebug))
+
+	err = ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{LoggerLevels: map[string]LogLevel{"panda": "bear"}})
+	require.ErrorIs(t, err, ErrInvalidLogLevel)
+}
+
+func TestOutputs(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf bytes.Buffer
+
+	ctx = TestZapOverrides(ctx, t, &buf, nil, zap.AddStacktrace(LevelInfo))
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	warningsPath := filepath.Join(t.TempDir(), "warnings.log")
+
+	err := ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{
+		Level: LevelDebug,
+		Outputs: []OutputSpec{
+			{Format: FormatJSON, File: &FileSpec{Path: path}},
+			{Format: FormatCLI, Level: levelPtr(LevelInfo)},
+			{File: &FileSpec{Path: warningsPath}, Level: levelPtr(LevelWarning)},
+		},
+		LoggerLevels: map[string]LogLevel{"chatty": LevelTrace},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	Info("to both")
+	Debug("only to the file")
+	WithName("chatty").Trace("overrides still apply to the file")
+	Trace("not logged at all")
+	Warning("warning to all")
+	Error("error to all", ErrInvalidLogLevel)
+
+	var messages []string
+	for _, line := range readLines(t, path, false) {
+		var entry struct {
+			Message    string `json:"message"`
+			Stacktrace string `json:"stacktrace"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		require.NotEmpty(t, entry.Stacktrace, "stack trace policy applies to json outputs")
+		messages = append(messages, entry.Message)
+	}
+	require.Equal(t, []string{
+		"to both",
+		"only to the file",
+		"overrides still apply to the file",
+		"warning to all",
+		"error to all",
+	}, messages)
+
+	require.Equal(t, []string{`"warning to all"`, `"error to all"`}, fileMessages(t, warningsPath), "an output can be restricted to warnings and errors")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3, "console output is restricted to info and omits stack traces")
+	require.Contains(t, lines[0], "  to both")
+	require.Contains(t, lines[1], "  warning to all")
+	require.Contains(t, lines[2], "  error to all  {\"error\": \""+ErrInvalidLogLevel.Error()+"\"}")
+
+	for _, invalid := range []LogSpec{
+		{Format: FormatText, Outputs: []OutputSpec{{}}},
+		{File: &FileSpec{Path: path}, Outputs: []OutputSpec{{}}},
+		{Outputs: []OutputSpec{{Format: FormatText}}},
+	} {
+		require.ErrorIs(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, invalid), ErrInvalidLogOutputs)
+	}
+	require.ErrorIs(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Outputs: []OutputSpec{{Level: levelPtr("panda")}}}), ErrInvalidLogLevel)
+	require.ErrorIs(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Outputs: []OutputSpec{{File: &FileSpec{}}}}), ErrInvalidLogFile)
+}
+
 func contains(haystack []LogLevel, needle LogLevel) bool {
 	for _, hay := range haystack {
 		if hay == needle {
@@ -387,6 +527,11 @@ func undoGlobalLogLevelChanges(t *testing.T, originalLogLevel klog.Level) {
 	t.Helper()
 	_, err := logs.GlogSetter(strconv.Itoa(int(originalLogLevel)))
 	require.NoError(t, err)
+
+	// this is not a change made outside of mlog, see reconcileKlogLevelLocked
+	globalSpecLock.Lock()
+	globalKlogVerbosity = originalLogLevel
+	globalSpecLock.Unlock()
 }
 
 func getKlogLevel() klog.Level {
@@ -400,3 +545,7 @@ func getKlogLevel() klog.Level {
 
 	return -1
 }
+
+func levelPtr(level LogLevel) *LogLevel {
+	return &level
+}
@@ -0,0 +1,210 @@
+package mlog
+
+import (
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultEarlyBufferSize = 1000
+
+// SetupOption configures Setup.
+type SetupOption func(*setupOptions)
+
+type setupOptions struct {
+	earlyBufferSize int // zero means that early logs are not buffered
+}
+
+// WithEarlyBuffer holds logs that are emitted before ValidateAndSetLogLevelAndFormatGlobally is called in memory,
+// and replays them through the configured logger once the config is applied.  This prevents a server that is
+// configured to use a non-default format or output from emitting a mix of formats.  At most maxEntries logs are
+// held (a default is used when maxEntries is not positive), and any further logs are dropped and counted.
+// The logs are written to stderr in the default JSON format if the config is never applied, i.e. when the func
+// returned by Setup is called or Fatal is called before ValidateAndSetLogLevelAndFormatGlobally succeeds.
+func WithEarlyBuffer(maxEntries int) SetupOption {
+	return func(o *setupOptions) {
+		o.earlyBufferSize = maxEntries
+		if o.earlyBufferSize <= 0 {
+			o.earlyBufferSize = defaultEarlyBufferSize
+		}
+	}
+}
+
+type bufferedEntry struct {
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// earlyBuffer holds log entries until they can be written through the final logger.
+type earlyBuffer struct {
+	mu       sync.Mutex
+	entries  []bufferedEntry
+	max      int
+	dropped  int
+	target   func(zapcore.Entry, []zapcore.Field) // set once the buffer has been drained
+	fallback loggers                              // the loggers that were replaced by the buffer
+}
+
+// startEarlyBufferLocked replaces the global loggers with one that buffers all entries.
+// the caller must hold globalSpecLock.
+func startEarlyBufferLocked(maxEntries int) {
+	if globalEarlyBuffer != nil {
+		return // already buffering
+	}
+
+	b := &earlyBuffer{max: maxEntries, fallback: *globalLoggers.Load()}
+
+	// use the same level checks, stack traces and trimming as the loggers built by newZapr
+	var core zapcore.Core = &bufferCore{buffer: b}
+	core = &levelCore{core: core, level: globalLevel, levels: &globalLoggerLevels, vmodule: &globalVModule}
+	core = &trimCore{core: core}
+	log := zap.New(core, zap.AddCaller(), zap.AddStacktrace(LevelTrace))
+
+	globalEarlyBuffer = b
+	// there is nothing to flush or release until the buffer is drained
+	setGlobalLoggers(&loggers{log: zapr.NewLogger(log), flush: func() error { return nil }, release: func() error { return nil }})
+}
+
+// replayEarlyBufferLocked writes all buffered entries through log, which must already be set as the global logger.
+// the caller must hold globalSpecLock.
+func replayEarlyBufferLocked(log logr.Logger) {
+	b := globalEarlyBuffer
+	if b == nil {
+		return
+	}
+	globalEarlyBuffer = nil
+
+	b.replay(log)
+}
+
+// dumpEarlyBuffer writes all buffered entries through the loggers that were in use before the buffer was started.
+// it is used when the process exits before the config is applied.
+func dumpEarlyBuffer() {
+	globalSpecLock.Lock()
+	defer globalSpecLock.Unlock()
+
+	b := globalEarlyBuffer
+	if b == nil {
+		return
+	}
+	globalEarlyBuffer = nil
+
+	setGlobalLoggers(&b.fallback)
+	b.replay(b.fallback.log)
+	_ = b.fallback.flush()
+}
+
+func (b *earlyBuffer) replay(log logr.Logger) {
+	if dropped := b.drain(replayer(log)); dropped > 0 {
+		Warning("dropped early logs because the buffer was full", "count", dropped)
+	}
+}
+
+// drain writes all buffered entries to target and forwards any further entries directly to it, which
+// handles loggers that were retrieved before the buffer was drained.  it returns the count of dropped entries.
+func (b *earlyBuffer) drain(target func(zapcore.Entry, []zapcore.Field)) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, e := range b.entries {
+		target(e.ent, e.fields)
+	}
+	b.entries = nil
+	b.target = target
+
+	return b.dropped
+}
+
+func (b *earlyBuffer) add(ent zapcore.Entry, fields []zapcore.Field) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.target != nil {
+		b.target(ent, fields)
+		return
+	}
+
+	if len(b.entries) >= b.max {
+		b.dropped++
+		return
+	}
+
+	b.entries = append(b.entries, bufferedEntry{ent: ent, fields: fields})
+}
+
+// replayer returns a func that re-encodes entries via log.  zap based loggers preserve the original
+// timestamp, caller and stack trace while other loggers (i.e. the text format) make a best effort.
+func replayer(log logr.Logger) func(zapcore.Entry, []zapcore.Field) {
+	if underlier, ok := log.GetSink().(zapr.Underlier); ok {
+		core := underlier.GetUnderlying().Core()
+		return func(ent zapcore.Entry, fields []zapcore.Field) {
+			if ce := core.Check(ent, nil); ce != nil {
+				ce.Write(fields...)
+			}
+		}
+	}
+
+	return func(ent zapcore.Entry, fields []zapcore.Field) {
+		l := log
+		if len(ent.LoggerName) > 0 {
+			l = l.WithName(ent.LoggerName)
+		}
+
+		var err error
+		keysAndValues := make([]interface{}, 0, 2*len(fields))
+		for _, field := range fields {
+			if field.Type == zapcore.ErrorType && field.Key == errorKey && ent.Level > zapcore.InfoLevel {
+				err, _ = field.Interface.(error)
+				continue
+			}
+			enc := zapcore.NewMapObjectEncoder()
+			field.AddTo(enc)
+			keysAndValues = append(keysAndValues, field.Key, enc.Fields[field.Key])
+		}
+
+		if ent.Level > zapcore.InfoLevel {
+			l.Error(err, ent.Message, keysAndValues...)
+			return
+		}
+		l.V(int(-ent.Level)).Info(ent.Message, keysAndValues...) // klog levels are inverted when zap handles them
+	}
+}
+
+var _ zapcore.Core = &bufferCore{}
+
+// bufferCore stores entries in an earlyBuffer.  it does not perform any level checks.
+type bufferCore struct {
+	buffer *earlyBuffer
+	fields []zapcore.Field
+}
+
+func (c *bufferCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *bufferCore) With(fields []zapcore.Field) zapcore.Core {
+	return &bufferCore{buffer: c.buffer, fields: c.withFields(fields)}
+}
+
+func (c *bufferCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c *bufferCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.buffer.add(ent, c.withFields(fields))
+	return nil
+}
+
+func (c *bufferCore) Sync() error {
+	return nil
+}
+
+// withFields returns a new slice so that the caller's fields are never retained or mutated.
+func (c *bufferCore) withFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	out = append(out, c.fields...)
+	return append(out, fields...)
+}
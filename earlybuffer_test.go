@@ -0,0 +1,131 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestEarlyBuffer(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	t.Cleanup(func() {
+		dumpEarlyBuffer()
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+		require.NoError(t, Shutdown(context.Background()))
+	})
+
+	globalSpecLock.Lock()
+	startEarlyBufferLocked(4)
+	globalSpecLock.Unlock()
+
+	early := Logr()
+
+	Always("early", "panda", 1)
+	WithName("bear").Warning("early warning")
+	klog.InfoS("from klog")
+	Debug("not enabled")
+	Error("early error", errors.New("oops"))
+	Always("dropped")
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelDebug}))
+
+	early.Info("late") // loggers that were retrieved before the config was applied are forwarded to the new logger
+
+	lines := jsonLines(t, buf.Bytes())
+	require.Len(t, lines, 6)
+
+	require.Equal(t, "early", lines[0]["message"])
+	require.Equal(t, float64(1), lines[0]["panda"])
+	require.Equal(t, "early warning", lines[1]["message"])
+	require.Equal(t, "bear", lines[1]["logger"])
+	require.Equal(t, "warning", lines[1]["level"])
+	require.Equal(t, "from klog", lines[2]["message"])
+	require.Equal(t, "early error", lines[3]["message"])
+	require.Equal(t, "oops", lines[3]["error"])
+	require.Equal(t, "error", lines[3]["level"])
+	for _, line := range lines[:4] {
+		require.Contains(t, line["caller"], "earlybuffer_test.go:", "the original caller must be preserved")
+	}
+
+	require.Equal(t, "dropped early logs because the buffer was full", lines[4]["message"])
+	require.Equal(t, float64(1), lines[4]["count"])
+	require.Equal(t, "late", lines[5]["message"])
+
+	// the buffer is only used once
+	Always("after")
+	require.Len(t, jsonLines(t, buf.Bytes()), 7)
+}
+
+func TestEarlyBufferDump(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	// use the test writer for the loggers that the buffer replaces
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+		require.NoError(t, Shutdown(context.Background()))
+	})
+	fallback := Logr()
+
+	globalSpecLock.Lock()
+	startEarlyBufferLocked(10)
+	globalSpecLock.Unlock()
+
+	Always("never configured")
+	require.Empty(t, buf.Bytes())
+
+	// the config is never applied, i.e. the process is exiting
+	dumpEarlyBuffer()
+
+	lines := jsonLines(t, buf.Bytes())
+	require.Len(t, lines, 1)
+	require.Equal(t, "never configured", lines[0]["message"])
+	require.Contains(t, lines[0]["caller"], "earlybuffer_test.go:")
+
+	require.Equal(t, fallback, Logr())
+
+	dumpEarlyBuffer() // no-op when not buffering
+	require.Len(t, jsonLines(t, buf.Bytes()), 1)
+}
+
+func jsonLines(t *testing.T, data []byte) []map[string]interface{} {
+	t.Helper()
+
+	var out []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal(line, &m), string(line))
+		out = append(out, m)
+	}
+	return out
+}
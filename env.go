@@ -0,0 +1,60 @@
+package mlog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	// EnvLevel is the environment variable that SetupFromEnv reads the LogSpec level from.
+	EnvLevel = "MLOG_LEVEL"
+	// EnvFormat is the environment variable that SetupFromEnv reads the LogSpec format from.
+	EnvFormat = "MLOG_FORMAT"
+)
+
+// SetupFromEnv is like Setup but also immediately applies the log level and format from the MLOG_LEVEL and
+// MLOG_FORMAT environment variables, which allows logs emitted before config parsing to respect them.
+// The values are validated using the same rules as a LogSpec parsed from JSON, and an unset or empty
+// environment variable is the same as omitting the field.  The environment only provides the initial
+// config: a later call to ValidateAndSetLogLevelAndFormatGlobally replaces it entirely, i.e. an explicit
+// LogSpec always takes precedence, even for the fields that it leaves unset.
+func SetupFromEnv(ctx context.Context) (func(), error) {
+	flush := Setup()
+	return flush, setFromEnv(ctx)
+}
+
+func setFromEnv(ctx context.Context) error {
+	spec, ok, err := specFromEnv()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil // keep the default config
+	}
+
+	return ValidateAndSetLogLevelAndFormatGlobally(ctx, spec)
+}
+
+// specFromEnv returns the LogSpec described by the environment and whether any of it was set.
+func specFromEnv() (LogSpec, bool, error) {
+	level, format := os.Getenv(EnvLevel), os.Getenv(EnvFormat)
+
+	var spec LogSpec
+
+	if len(level) > 0 {
+		spec.Level = LogLevel(level)
+		if klogLevelForMlogLevel(spec.Level) < 0 {
+			return LogSpec{}, false, fmt.Errorf("invalid %s: %w", EnvLevel, ErrInvalidLogLevel)
+		}
+	}
+
+	if len(format) > 0 {
+		if err := spec.Format.UnmarshalJSON([]byte(strconv.Quote(format))); err != nil {
+			return LogSpec{}, false, fmt.Errorf("invalid %s: %w", EnvFormat, err)
+		}
+	}
+
+	return spec, len(level) > 0 || len(format) > 0, nil
+}
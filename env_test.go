@@ -0,0 +1,101 @@
+package mlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestSpecFromEnv(t *testing.T) {
+	tests := []struct {
+		name, level, format string
+		want                LogSpec
+		wantOK              bool
+		wantErr             error
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:   "level",
+			level:  "debug",
+			want:   LogSpec{Level: LevelDebug},
+			wantOK: true,
+		},
+		{
+			name:   "format",
+			format: "text",
+			want:   LogSpec{Format: FormatText},
+			wantOK: true,
+		},
+		{
+			name:   "both",
+			level:  "all",
+			format: "json",
+			want:   LogSpec{Level: LevelAll, Format: FormatJSON},
+			wantOK: true,
+		},
+		{
+			name:    "invalid level",
+			level:   "panda",
+			wantErr: ErrInvalidLogLevel,
+		},
+		{
+			name:    "cli format is not allowed",
+			format:  "cli",
+			wantErr: ErrInvalidLogFormat,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvLevel, tt.level)
+			t.Setenv(EnvFormat, tt.format)
+
+			spec, ok, err := specFromEnv()
+			require.True(t, errors.Is(err, tt.wantErr), "unexpected error: %v", err)
+			require.Equal(t, tt.want, spec)
+			require.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
+
+func TestSetFromEnv(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	t.Setenv(EnvLevel, "trace")
+	t.Setenv(EnvFormat, "")
+
+	// SetupFromEnv is not called directly because klog's flush daemon races with changes to the global logger
+	require.NoError(t, setFromEnv(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	require.Equal(t, LogSpec{Level: LevelTrace, Format: FormatJSON}, CurrentSpec())
+	require.True(t, Enabled(LevelTrace))
+
+	// an explicit spec replaces the environment entirely
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{}))
+	require.Equal(t, LogSpec{Format: FormatJSON}, CurrentSpec())
+	require.False(t, Enabled(LevelInfo))
+
+	t.Setenv(EnvLevel, "panda")
+	err := setFromEnv(ctx)
+	require.Equal(t, `invalid MLOG_LEVEL: `+ErrInvalidLogLevel.Error(), errString(err))
+	require.Equal(t, LogSpec{Format: FormatJSON}, CurrentSpec())
+}
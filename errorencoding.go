@@ -0,0 +1,313 @@
+package mlog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxErrorChain bounds how far an error chain is unwrapped, which guards against errors that unwrap to themselves.
+const maxErrorChain = 32
+
+// ErrorSpec configures how errors are encoded by the json and cli formats.  For an error logged under the
+// error key, the additional details are logged under errorType, errorChain, errorJoined, errorVerbose,
+// errorCode, errorReason and errorDetails.  When any option is set, errors are encoded as described here
+// instead of zap's default encoding, which adds errorCauses for multi-errors and errorVerbose for all errors
+// that implement fmt.Formatter.  The deprecated text format only supports APIStatus.
+type ErrorSpec struct {
+	// Type adds the concrete type of the error, i.e. *fs.PathError.
+	Type bool `json:"type,omitempty"`
+	// Chain adds the message of each error that is found by repeatedly calling errors.Unwrap.
+	Chain bool `json:"chain,omitempty"`
+	// Joined adds the message of each member of the first error in the chain that wraps multiple
+	// errors, i.e. one created via errors.Join or a multi-error with an Errors() []error method.
+	Joined bool `json:"joined,omitempty"`
+	// Verbose adds the %+v form of the error (if it differs from the message) at the trace and all levels.
+	Verbose bool `json:"verbose,omitempty"`
+	// APIStatus adds the HTTP code, reason and details (group, kind, name and causes) of Kubernetes API
+	// status errors, i.e. the errors returned by client-go.  The text format flattens the details into
+	// keys such as errorDetails.kind.
+	APIStatus bool `json:"apiStatus,omitempty"`
+}
+
+func (s *ErrorSpec) deepCopy() *ErrorSpec {
+	if s == nil {
+		return nil
+	}
+	out := *s
+	return &out
+}
+
+func (s *ErrorSpec) enabled() bool {
+	return s != nil && (s.Type || s.Chain || s.Joined || s.Verbose || s.APIStatus)
+}
+
+// textIgnored reports if spec enables any option that is not supported by the text format.
+func (s *ErrorSpec) textIgnored() bool {
+	return s != nil && (s.Type || s.Chain || s.Joined || s.Verbose)
+}
+
+// errorCoreOption wraps the core in an errorCore if spec enables any option.  verbose controls when the verbose
+// form is included, see zapLevels.trace.  it must be applied before warningCoreOption so that the warningCore
+// is the core that is added to the checked entry.
+func errorCoreOption(spec *ErrorSpec, verbose zapcore.LevelEnabler) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if !spec.enabled() {
+			return core
+		}
+		return &errorCore{core: core, spec: *spec, verbose: verbose}
+	})
+}
+
+var _ zapcore.Core = &errorCore{}
+
+// errorCore replaces error fields with an inline object that encodes the error as described by its spec.
+type errorCore struct {
+	core    zapcore.Core
+	spec    ErrorSpec
+	verbose zapcore.LevelEnabler
+}
+
+func (e *errorCore) Enabled(level zapcore.Level) bool {
+	return e.core.Enabled(level)
+}
+
+func (e *errorCore) With(fields []zapcore.Field) zapcore.Core {
+	// fields added via With are only encoded once, so the verbose form is decided when With is called
+	verbose := e.spec.Verbose && e.verbose.Enabled(zapcore.InfoLevel) // the level is ignored, see zapLevels.trace
+	return &errorCore{core: e.core.With(e.richFields(fields, verbose)), spec: e.spec, verbose: e.verbose}
+}
+
+func (e *errorCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if e.core.Enabled(ent.Level) {
+		return ce.AddCore(ent, e)
+	}
+	return ce
+}
+
+func (e *errorCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return e.core.Write(ent, e.richFields(fields, e.spec.Verbose && e.verbose.Enabled(ent.Level)))
+}
+
+func (e *errorCore) Sync() error {
+	return e.core.Sync()
+}
+
+// richFields returns a new slice so that the caller's fields are never mutated.
+func (e *errorCore) richFields(fields []zapcore.Field, verbose bool) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		if f.Type != zapcore.ErrorType {
+			continue
+		}
+		err, ok := f.Interface.(error)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = append(make([]zapcore.Field, 0, len(fields)), fields...)
+		}
+		out[i] = zap.Inline(richError{key: f.Key, err: err, spec: e.spec, verbose: verbose})
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+var _ zapcore.ObjectMarshaler = richError{}
+
+type richError struct {
+	key     string
+	err     error
+	spec    ErrorSpec
+	verbose bool
+}
+
+func (r richError) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	msg := errorMessage(r.err)
+	enc.AddString(r.key, msg)
+
+	if r.spec.Type {
+		enc.AddString(r.key+"Type", fmt.Sprintf("%T", r.err))
+	}
+
+	if r.spec.Chain {
+		var chain errorMessages
+		for err, i := errors.Unwrap(r.err), 0; err != nil && i < maxErrorChain; err, i = errors.Unwrap(err), i+1 {
+			chain = append(chain, err)
+		}
+		if len(chain) > 0 {
+			if err := enc.AddArray(r.key+"Chain", chain); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.spec.Joined {
+		if joined := joinedErrors(r.err); len(joined) > 0 {
+			if err := enc.AddArray(r.key+"Joined", errorMessages(joined)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.verbose {
+		if verbose := fmt.Sprintf("%+v", r.err); verbose != msg {
+			enc.AddString(r.key+"Verbose", verbose)
+		}
+	}
+
+	if r.spec.APIStatus {
+		if status, ok := apiStatus(r.err); ok {
+			return addAPIStatus(enc, r.key, status)
+		}
+	}
+
+	return nil
+}
+
+// joinedErrors returns the members of the first error in the chain that wraps multiple errors.
+func joinedErrors(err error) []error {
+	for i := 0; err != nil && i < maxErrorChain; err, i = errors.Unwrap(err), i+1 {
+		switch e := err.(type) { //nolint:errorlint // each error in the chain is checked on its own
+		case interface{ Unwrap() []error }: // errors.Join and fmt.Errorf with multiple %w verbs
+			return e.Unwrap()
+		case interface{ Errors() []error }: // multi-errors such as k8s.io/apimachinery/pkg/util/errors.Aggregate
+			return e.Errors()
+		}
+	}
+	return nil
+}
+
+// errorMessage matches zap's handling of errors that panic, which is usually caused by a nil pointer receiver.
+func errorMessage(err error) (msg string) {
+	defer func() {
+		if p := recover(); p != nil {
+			if v := reflect.ValueOf(err); v.Kind() == reflect.Ptr && v.IsNil() {
+				msg = "<nil>"
+				return
+			}
+			msg = fmt.Sprintf("<PANIC=%v>", p)
+		}
+	}()
+	return err.Error()
+}
+
+var _ zapcore.ArrayMarshaler = errorMessages{}
+
+type errorMessages []error
+
+func (e errorMessages) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, err := range e {
+		if err == nil {
+			enc.AppendString("<nil>")
+			continue
+		}
+		enc.AppendString(errorMessage(err))
+	}
+	return nil
+}
+
+// newErrorTextLogger returns a logger that adds the API status of errors as additional keys and values when
+// spec enables APIStatus, which is the only option that is supported by the text format.
+func newErrorTextLogger(log logr.Logger, spec *ErrorSpec) logr.Logger {
+	if spec == nil || !spec.APIStatus {
+		return log
+	}
+	sink := log.GetSink()
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(1) // account for the extra frame from errorSink
+	}
+	return logr.New(&errorSink{sink: sink})
+}
+
+var _ logr.CallDepthLogSink = &errorSink{}
+
+type errorSink struct {
+	sink logr.LogSink
+}
+
+func (e *errorSink) Init(logr.RuntimeInfo) {
+	// the wrapped sink was already initialized by its logger and is adjusted for our call depth by newErrorTextLogger
+}
+
+func (e *errorSink) Enabled(level int) bool {
+	return e.sink.Enabled(level)
+}
+
+func (e *errorSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	e.sink.Info(level, msg, withAPIStatus(keysAndValues)...)
+}
+
+func (e *errorSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	keysAndValues = withAPIStatus(keysAndValues)
+	if status, ok := apiStatus(err); ok {
+		keysAndValues = append(keysAndValues[:len(keysAndValues):len(keysAndValues)], apiStatusKeysAndValues(errorKey, status)...)
+	}
+	e.sink.Error(err, msg, keysAndValues...)
+}
+
+func (e *errorSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &errorSink{sink: e.sink.WithValues(withAPIStatus(keysAndValues)...)}
+}
+
+func (e *errorSink) WithName(name string) logr.LogSink {
+	return &errorSink{sink: e.sink.WithName(name)}
+}
+
+func (e *errorSink) WithCallDepth(depth int) logr.LogSink {
+	withCallDepth, ok := e.sink.(logr.CallDepthLogSink)
+	if !ok {
+		return e
+	}
+	return &errorSink{sink: withCallDepth.WithCallDepth(depth)}
+}
+
+// withAPIStatus returns a new slice with the API status of each error value added after its key and value.
+// the caller's slice is returned as is if it does not contain any API status errors.
+func withAPIStatus(keysAndValues []interface{}) []interface{} {
+	var out []interface{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		key, value := keysAndValues[i], keysAndValues[i+1]
+		extra := apiStatusKeysAndValuesFor(key, value)
+		if len(extra) == 0 {
+			if out != nil {
+				out = append(out, key, value)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]interface{}, 0, len(keysAndValues)+len(extra)), keysAndValues[:i]...)
+		}
+		out = append(out, key, value)
+		out = append(out, extra...)
+	}
+	if out == nil {
+		return keysAndValues
+	}
+	if len(keysAndValues)%2 == 1 {
+		out = append(out, keysAndValues[len(keysAndValues)-1]) // keep the key without a value so that the sink can report it
+	}
+	return out
+}
+
+func apiStatusKeysAndValuesFor(key, value interface{}) []interface{} {
+	k, ok := key.(string)
+	if !ok {
+		return nil
+	}
+	err, ok := value.(error)
+	if !ok {
+		return nil
+	}
+	status, ok := apiStatus(err)
+	if !ok {
+		return nil
+	}
+	return apiStatusKeysAndValues(k, status)
+}
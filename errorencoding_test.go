@@ -0,0 +1,264 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestErrorSpec(t *testing.T) {
+	pathErr := &fs.PathError{Op: "open", Path: "/etc/panda", Err: fs.ErrNotExist}
+	wrapped := fmt.Errorf("failed to read config: %w", pathErr)
+	joined := fmt.Errorf("validation failed: %w", &testMultiError{errs: []error{errors.New("a is bad"), errors.New("b is bad")}})
+	aggregate := utilerrors.NewAggregate([]error{errors.New("c is bad"), errors.New("d is bad")})
+	verbose := &testVerboseError{msg: "oops", details: "oops\nat some/stack.go:12"}
+	var nilErr *testVerboseError
+	notFound := fmt.Errorf("failed to get deployment: %w", apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, "panda"))
+	invalid := apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "bear", field.ErrorList{
+		field.Invalid(field.NewPath("spec", "replicas"), -1, "must be greater than or equal to 0"),
+	})
+	var nilStatus *apierrors.StatusError
+
+	all := &ErrorSpec{Type: true, Chain: true, Joined: true, Verbose: true}
+
+	tests := []struct {
+		name  string
+		spec  LogSpec
+		log   func(Logger)
+		want  map[string]interface{}
+		unset []string
+	}{
+		{
+			name: "unset",
+			spec: LogSpec{},
+			log:  func(l Logger) { l.Error("msg", wrapped) },
+			want: map[string]interface{}{
+				"error": "failed to read config: open /etc/panda: file does not exist",
+			},
+			unset: []string{"errorType", "errorChain", "errorJoined", "errorVerbose"},
+		},
+		{
+			name: "type and chain",
+			spec: LogSpec{Errors: &ErrorSpec{Type: true, Chain: true}},
+			log:  func(l Logger) { l.Error("msg", wrapped) },
+			want: map[string]interface{}{
+				"error":     "failed to read config: open /etc/panda: file does not exist",
+				"errorType": "*fmt.wrapError",
+				"errorChain": []interface{}{
+					"open /etc/panda: file does not exist",
+					"file does not exist",
+				},
+			},
+			unset: []string{"errorJoined", "errorVerbose"},
+		},
+		{
+			name: "joined via unwrap",
+			spec: LogSpec{Errors: &ErrorSpec{Joined: true}},
+			log:  func(l Logger) { l.Warning("msg", "error", joined) },
+			want: map[string]interface{}{
+				"error":       "validation failed: a is bad, b is bad",
+				"errorJoined": []interface{}{"a is bad", "b is bad"},
+			},
+			unset: []string{"errorType", "errorChain"},
+		},
+		{
+			name: "joined via aggregate",
+			spec: LogSpec{Errors: &ErrorSpec{Joined: true}},
+			log:  func(l Logger) { l.Error("msg", aggregate) },
+			want: map[string]interface{}{
+				"error":       "[c is bad, d is bad]",
+				"errorJoined": []interface{}{"c is bad", "d is bad"},
+			},
+			unset: []string{"errorCauses"},
+		},
+		{
+			name:  "verbose below trace",
+			spec:  LogSpec{Level: LevelDebug, Errors: all},
+			log:   func(l Logger) { l.Error("msg", verbose) },
+			want:  map[string]interface{}{"error": "oops", "errorType": "*mlog.testVerboseError"},
+			unset: []string{"errorVerbose"},
+		},
+		{
+			name: "verbose at trace",
+			spec: LogSpec{Level: LevelTrace, Errors: all},
+			log:  func(l Logger) { l.Error("msg", verbose) },
+			want: map[string]interface{}{
+				"error":        "oops",
+				"errorType":    "*mlog.testVerboseError",
+				"errorVerbose": "oops\nat some/stack.go:12",
+			},
+		},
+		{
+			name: "with values",
+			spec: LogSpec{Errors: all},
+			log:  func(l Logger) { l.WithValues("cause", pathErr).Always("msg") },
+			want: map[string]interface{}{
+				"cause":      "open /etc/panda: file does not exist",
+				"causeType":  "*fs.PathError",
+				"causeChain": []interface{}{"file does not exist"},
+			},
+		},
+		{
+			name: "api status",
+			spec: LogSpec{Errors: &ErrorSpec{APIStatus: true}},
+			log:  func(l Logger) { l.Error("msg", notFound) },
+			want: map[string]interface{}{
+				"error":        `failed to get deployment: deployments.apps "panda" not found`,
+				"errorCode":    float64(404),
+				"errorReason":  "NotFound",
+				"errorDetails": map[string]interface{}{"group": "apps", "kind": "deployments", "name": "panda"},
+			},
+			unset: []string{"errorType"},
+		},
+		{
+			name: "api status causes",
+			spec: LogSpec{Errors: &ErrorSpec{APIStatus: true}},
+			log:  func(l Logger) { l.Warning("msg", "cause", invalid) },
+			want: map[string]interface{}{
+				"causeCode":   float64(422),
+				"causeReason": "Invalid",
+				"causeDetails": map[string]interface{}{
+					"group": "apps",
+					"kind":  "Deployment",
+					"name":  "bear",
+					"causes": []interface{}{map[string]interface{}{
+						"reason":  "FieldValueInvalid",
+						"message": "Invalid value: -1: must be greater than or equal to 0",
+						"field":   "spec.replicas",
+					}},
+				},
+			},
+		},
+		{
+			name:  "api status disabled",
+			spec:  LogSpec{Errors: &ErrorSpec{Type: true}},
+			log:   func(l Logger) { l.Error("msg", notFound) },
+			want:  map[string]interface{}{"errorType": "*fmt.wrapError"},
+			unset: []string{"errorCode", "errorReason", "errorDetails"},
+		},
+		{
+			name:  "api status typed nil",
+			spec:  LogSpec{Errors: &ErrorSpec{APIStatus: true}},
+			log:   func(l Logger) { l.Error("msg", nilStatus) },
+			want:  map[string]interface{}{"error": "<nil>"},
+			unset: []string{"errorCode", "errorReason", "errorDetails"},
+		},
+		{
+			name: "typed nil",
+			spec: LogSpec{Errors: all},
+			log:  func(l Logger) { l.Error("msg", nilErr) },
+			want: map[string]interface{}{"error": "<nil>", "errorType": "*mlog.testVerboseError"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf syncBuffer
+			log, err := NewWithConfig(tt.spec, WithWriter(&buf))
+			require.NoError(t, err)
+
+			tt.log(log)
+			require.NoError(t, log.Close())
+
+			lines := jsonLines(t, buf.Bytes())
+			require.Len(t, lines, 1)
+			for key, value := range tt.want {
+				require.Equal(t, value, lines[0][key], key)
+			}
+			for _, key := range tt.unset {
+				require.NotContains(t, lines[0], key)
+			}
+		})
+	}
+}
+
+func TestErrorSpecCLI(t *testing.T) {
+	var buf syncBuffer
+	log, err := NewWithConfig(LogSpec{Format: FormatCLI, Errors: &ErrorSpec{Type: true, APIStatus: true}}, WithWriter(&buf))
+	require.NoError(t, err)
+
+	log.Error("msg", errors.New("oops"))
+	log.Error("msg", apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "panda", errors.New("try again")))
+	require.NoError(t, log.Close())
+
+	lines := strings.Split(strings.TrimSpace(string(buf.Bytes())), "\n")
+	require.Len(t, lines, 2)
+	require.True(t, strings.HasSuffix(lines[0], `{"error": "oops", "errorType": "*errors.errorString"}`), lines[0])
+	require.True(t, strings.HasSuffix(lines[1], `"errorType": "*errors.StatusError", "errorCode": 409, "errorReason": "Conflict", "errorDetails": {"kind": "configmaps", "name": "panda"}}`), lines[1])
+}
+
+func TestErrorSpecAPIStatusText(t *testing.T) {
+	invalid := apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "bear", field.ErrorList{
+		field.Invalid(field.NewPath("spec", "replicas"), -1, "must be greater than or equal to 0"),
+		field.Required(field.NewPath("spec", "selector"), ""),
+	})
+
+	var buf bytes.Buffer
+	ctx := TestZapOverrides(context.Background(), t, &buf, nil)
+	text, _, err := newTextLogr(ctx, 0, logOutput{encoding: "text", errors: &ErrorSpec{APIStatus: true}})
+	require.NoError(t, err)
+
+	l := mLogger{log: &text.log}
+	l.Error("failed", invalid)
+	l.WithValues("cause", invalid).Warning("retrying", "panda", 1)
+	l.Always("unrelated", "error", errors.New("oops"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+
+	const details = `errorDetails.group="apps" errorDetails.kind="Deployment" errorDetails.name="bear" ` +
+		`errorDetails.causes="spec.replicas: Invalid value: -1: must be greater than or equal to 0; spec.selector: Required value"`
+	require.True(t, strings.HasPrefix(lines[0], "E"), lines[0])
+	require.Contains(t, lines[0], `errorCode=422 errorReason="Invalid" `+details)
+	require.Contains(t, lines[0], "errorencoding_test.go:", "the caller must account for the extra frames")
+
+	require.True(t, strings.HasPrefix(lines[1], "W"), lines[1])
+	require.True(t, strings.HasSuffix(lines[1], `causeDetails.causes="spec.replicas: Invalid value: -1: must be greater than or equal to 0; spec.selector: Required value" panda=1`), lines[1])
+	require.Contains(t, lines[1], `causeCode=422 causeReason="Invalid" causeDetails.group="apps"`)
+	require.NotContains(t, lines[1], "warning=true")
+
+	require.True(t, strings.HasSuffix(lines[2], `"unrelated" error="oops"`), lines[2])
+}
+
+type testMultiError struct {
+	errs []error
+}
+
+func (e *testMultiError) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, ", ")
+}
+
+func (e *testMultiError) Unwrap() []error {
+	return e.errs
+}
+
+type testVerboseError struct {
+	msg, details string
+}
+
+func (e *testVerboseError) Error() string {
+	return e.msg
+}
+
+func (e *testVerboseError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		_, _ = fmt.Fprint(s, e.details)
+		return
+	}
+	_, _ = fmt.Fprint(s, e.msg)
+}
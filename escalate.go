@@ -0,0 +1,86 @@
+package mlog
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"k8s.io/klog/v2"
+)
+
+const errInvalidEscalationDuration = constableError("invalid log level escalation duration, it must be positive")
+
+type escalation struct {
+	klogLevel klog.Level
+}
+
+// EscalateLevel temporarily raises the global log level to at least level.  The escalation is automatically
+// reverted once d has elapsed or ctx is cancelled, whichever happens first.  Escalations may overlap, in which
+// case the most verbose of the configured level and all active escalations is used.  Changing the config while
+// an escalation is active only changes the level that is restored once all escalations have been reverted.
+// Every transition is logged via Always to provide an audit trail.
+func EscalateLevel(ctx context.Context, level LogLevel, d time.Duration) error {
+	klogLevel := klogLevelForMlogLevel(level)
+	if klogLevel < 0 {
+		return ErrInvalidLogLevel
+	}
+
+	if d <= 0 {
+		return errInvalidEscalationDuration
+	}
+
+	var escalatedBy string
+	if _, file, line, ok := runtime.Caller(1); ok {
+		escalatedBy = zapcore.NewEntryCaller(0, file, line, ok).TrimmedPath()
+	}
+
+	e := &escalation{klogLevel: klogLevel}
+
+	oldLevel, newLevel := changeEscalations(func() {
+		globalEscalations = append(globalEscalations, e)
+	})
+
+	// the global logger attributes this log to the caller of EscalateLevel
+	logger.Always("log level escalated", "escalatedTo", level, "duration", d, "escalatedBy", escalatedBy,
+		"old", oldLevel, "new", newLevel)
+
+	go func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		reason := "expired"
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			reason = "cancelled"
+		}
+
+		oldLevel, newLevel := changeEscalations(func() {
+			for i, active := range globalEscalations {
+				if active == e {
+					globalEscalations = append(globalEscalations[:i:i], globalEscalations[i+1:]...)
+					break
+				}
+			}
+		})
+
+		Always("log level escalation reverted", "escalatedTo", level, "reason", reason, "escalatedBy", escalatedBy,
+			"old", oldLevel, "new", newLevel)
+	}()
+
+	return nil
+}
+
+func changeEscalations(f func()) (oldLevel, newLevel LogLevel) {
+	globalSpecLock.Lock()
+	defer globalSpecLock.Unlock()
+
+	oldLevel = zapLevelToMlogLevel(zapcore.Level(-effectiveKlogLevel()))
+	f()
+	updateLevelGlobally()
+	newLevel = zapLevelToMlogLevel(zapcore.Level(-effectiveKlogLevel()))
+
+	return oldLevel, newLevel
+}
@@ -0,0 +1,109 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestEscalateLevel(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelInfo}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	require.Equal(t, ErrInvalidLogLevel, EscalateLevel(ctx, "panda", time.Minute))
+	require.Equal(t, errInvalidEscalationDuration, EscalateLevel(ctx, LevelDebug, 0))
+
+	waitForReverts := func(n int) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			return bytes.Count(buf.Bytes(), []byte(`"message":"log level escalation reverted"`)) == n
+		}, time.Minute, 10*time.Millisecond)
+	}
+
+	debugCtx, debugCancel := context.WithCancel(ctx)
+	t.Cleanup(debugCancel)
+	require.NoError(t, EscalateLevel(debugCtx, LevelDebug, time.Hour))
+	require.Equal(t, klog.Level(4), getKlogLevel())
+	require.True(t, Enabled(LevelDebug))
+	require.False(t, Enabled(LevelTrace))
+
+	traceCtx, traceCancel := context.WithCancel(ctx)
+	t.Cleanup(traceCancel)
+	require.NoError(t, EscalateLevel(traceCtx, LevelTrace, time.Hour))
+	require.Equal(t, klog.Level(6), getKlogLevel())
+	require.Equal(t, LogSpec{Level: LevelTrace, Format: FormatJSON}, CurrentSpec())
+	require.Equal(t, LogSpec{Level: LevelInfo, Format: FormatJSON}, ConfiguredSpec())
+
+	// a less verbose escalation does not lower the level and is reverted on its own once it expires
+	require.NoError(t, EscalateLevel(ctx, LevelInfo, time.Millisecond))
+	waitForReverts(1)
+	require.Equal(t, klog.Level(6), getKlogLevel())
+
+	// the outer escalation still applies after the inner one is reverted
+	traceCancel()
+	waitForReverts(2)
+	require.Equal(t, klog.Level(4), getKlogLevel())
+
+	// config changes during an escalation only change the level that is eventually restored
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelWarning}))
+	require.Equal(t, klog.Level(4), getKlogLevel())
+	require.Equal(t, LogSpec{Level: LevelDebug, Format: FormatJSON}, CurrentSpec())
+	require.Equal(t, LogSpec{Level: LevelWarning, Format: FormatJSON}, ConfiguredSpec())
+
+	debugCancel()
+	waitForReverts(3)
+	require.Equal(t, klog.Level(0), getKlogLevel())
+	require.False(t, Enabled(LevelInfo))
+	require.Equal(t, LogSpec{Level: LevelWarning, Format: FormatJSON}, CurrentSpec())
+
+	type auditLog struct {
+		Message     string
+		EscalatedTo LogLevel
+		Reason      string
+		Old, New    LogLevel
+	}
+	var logs []auditLog
+	for _, line := range jsonLines(t, buf.Bytes()) {
+		message := line["message"].(string)
+		if message != "log level escalated" && message != "log level escalation reverted" {
+			continue
+		}
+		require.Contains(t, line["escalatedBy"], "/escalate_test.go:")
+		if message == "log level escalated" {
+			require.Contains(t, line["caller"], "/escalate_test.go:")
+		}
+		str := func(key string) string {
+			value, _ := line[key].(string)
+			return value
+		}
+		logs = append(logs, auditLog{Message: message, EscalatedTo: LogLevel(str("escalatedTo")), Reason: str("reason"), Old: LogLevel(str("old")), New: LogLevel(str("new"))})
+	}
+
+	require.Equal(t, []auditLog{
+		{Message: "log level escalated", EscalatedTo: LevelDebug, Old: LevelInfo, New: LevelDebug},
+		{Message: "log level escalated", EscalatedTo: LevelTrace, Old: LevelDebug, New: LevelTrace},
+		{Message: "log level escalated", EscalatedTo: LevelInfo, Old: LevelTrace, New: LevelTrace},
+		{Message: "log level escalation reverted", EscalatedTo: LevelInfo, Reason: "expired", Old: LevelTrace, New: LevelTrace},
+		{Message: "log level escalation reverted", EscalatedTo: LevelTrace, Reason: "cancelled", Old: LevelTrace, New: LevelDebug},
+		{Message: "log level escalation reverted", EscalatedTo: LevelDebug, Reason: "cancelled", Old: LevelDebug, New: LevelWarning},
+	}, logs)
+}
@@ -0,0 +1,491 @@
+package mlog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	defaultFileMode = 0o600
+
+	// backupTimeFormat is used to name rotated files, it sorts lexically and avoids characters that are invalid on windows
+	backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+	compressSuffix = ".gz"
+
+	ErrInvalidLogFile = constableError("invalid log file, path must be set and limits must not be negative")
+
+	errLogFileInUse = constableError("log file is already used by another logger with a different rotation config")
+)
+
+// FileSpec configures logging to a file with size based rotation.
+// Rotated files are named after the original file with the rotation timestamp appended to the base name.
+type FileSpec struct {
+	// Path is the file to log to.  It is created if it does not exist and appended to if it does.
+	Path string `json:"path"`
+	// MaxSize is the size at which the file is rotated.  The file is never rotated if MaxSize is unset.
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+	// MaxAge is how long rotated files are retained.  Rotated files are never deleted based on age if MaxAge is unset.
+	MaxAge metav1.Duration `json:"maxAge,omitempty"`
+	// MaxBackups is the number of rotated files to retain.  All rotated files are retained if MaxBackups is unset.
+	MaxBackups int `json:"maxBackups,omitempty"`
+	// Compress determines if rotated files are compressed using gzip.
+	Compress bool `json:"compress,omitempty"`
+	// Mode is the permission bits used for the log file and its rotated files, defaults to 0600 (384 in JSON).
+	Mode int32 `json:"mode,omitempty"`
+}
+
+func (f *FileSpec) validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if len(f.Path) == 0 {
+		errs = append(errs, field.Required(fldPath.Child("path"), ""))
+	}
+	if f.MaxSize != nil && f.MaxSize.Sign() < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxSize"), f.MaxSize.String(), "must not be negative"))
+	}
+	if f.MaxAge.Duration < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxAge"), f.MaxAge.Duration.String(), "must not be negative"))
+	}
+	if f.MaxBackups < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxBackups"), f.MaxBackups, "must not be negative"))
+	}
+	if f.Mode < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("mode"), f.Mode, "must not be negative"))
+	}
+	return errs
+}
+
+func (f *FileSpec) deepCopy() *FileSpec {
+	if f == nil {
+		return nil
+	}
+	out := *f
+	if f.MaxSize != nil {
+		maxSize := f.MaxSize.DeepCopy()
+		out.MaxSize = &maxSize
+	}
+	return &out
+}
+
+// rotatingFileConfig returns the config that is used to open the file, see acquireRotatingFile.
+func (f *FileSpec) rotatingFileConfig() (rotatingFileConfig, error) {
+	path, err := filepath.Abs(f.Path)
+	if err != nil {
+		return rotatingFileConfig{}, fmt.Errorf("invalid log file path: %w", err)
+	}
+
+	config := rotatingFileConfig{
+		path:       path,
+		maxAge:     f.MaxAge.Duration,
+		maxBackups: f.MaxBackups,
+		compress:   f.Compress,
+		mode:       defaultFileMode,
+	}
+	if f.MaxSize != nil {
+		config.maxSize = f.MaxSize.Value()
+	}
+	if f.Mode > 0 {
+		config.mode = os.FileMode(f.Mode)
+	}
+	return config, nil
+}
+
+// openRotatingFile returns a reference to the shared rotatingFile for f, which must be closed once it is no longer used.
+func openRotatingFile(f *FileSpec, global bool) (zap.Sink, error) {
+	config, err := f.rotatingFileConfig()
+	if err != nil {
+		return nil, err
+	}
+	return acquireRotatingFile(config, global)
+}
+
+// closeSinks closes all sinks and returns the first error.
+func closeSinks(sinks []zap.Sink) error {
+	var firstErr error
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+//nolint:gochecknoglobals
+var (
+	// a single rotatingFile is shared across all loggers for a given path to prevent multiple writers
+	// from attempting to rotate the same file.  the file is closed once the last logger releases it.
+	rotatingFilesLock sync.Mutex
+	rotatingFiles     = map[string]*rotatingFile{}
+)
+
+// acquireRotatingFile returns a reference to the shared rotatingFile for config.path, which must be closed once
+// it is no longer used.  the rotation config of a file that is in use can only be changed by the global config,
+// which replaces its own references whenever it changes.  all other loggers must use the same rotation config.
+func acquireRotatingFile(config rotatingFileConfig, global bool) (zap.Sink, error) {
+	rotatingFilesLock.Lock()
+	defer rotatingFilesLock.Unlock()
+
+	r, ok := rotatingFiles[config.path]
+	switch {
+	case !ok:
+		r = &rotatingFile{now: time.Now, config: config, mill: make(chan struct{}, 1)}
+		go r.millRun()
+		rotatingFiles[config.path] = r
+	case r.currentConfig() == config:
+	case global && r.refs == r.globalRefs:
+		r.setConfig(config)
+	default:
+		return nil, fmt.Errorf("%w: %s", errLogFileInUse, config.path)
+	}
+
+	r.refs++
+	if global {
+		r.globalRefs++
+	}
+
+	return &rotatingFileRef{rotatingFile: r, global: global}, nil
+}
+
+func releaseRotatingFile(r *rotatingFile, global bool) error {
+	rotatingFilesLock.Lock()
+	defer rotatingFilesLock.Unlock()
+
+	r.refs--
+	if global {
+		r.globalRefs--
+	}
+	if r.refs > 0 {
+		return nil
+	}
+
+	delete(rotatingFiles, r.currentConfig().path)
+	return r.close()
+}
+
+var _ zap.Sink = &rotatingFileRef{}
+
+// rotatingFileRef is a zap.Sink that releases its reference to the shared rotatingFile when it is closed.
+type rotatingFileRef struct {
+	*rotatingFile
+	global bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func (r *rotatingFileRef) Close() error {
+	r.closeOnce.Do(func() {
+		r.closeErr = releaseRotatingFile(r.rotatingFile, r.global)
+	})
+	return r.closeErr
+}
+
+type rotatingFileConfig struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	mode       os.FileMode
+}
+
+// rotatingFile is safe for concurrent use.  rotated files are compressed and cleaned up in a background
+// go routine to avoid blocking log calls.
+type rotatingFile struct {
+	now func() time.Time
+
+	refs, globalRefs int // guarded by rotatingFilesLock, see acquireRotatingFile
+
+	mu     sync.Mutex
+	config rotatingFileConfig
+	file   *os.File
+	size   int64
+	closed bool
+
+	mill chan struct{}
+}
+
+func (r *rotatingFile) currentConfig() rotatingFileConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.config
+}
+
+func (r *rotatingFile) setConfig(config rotatingFileConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.config = config
+
+	if r.file != nil {
+		_ = r.file.Chmod(config.mode) // best effort, the next rotation will use the correct mode regardless
+	}
+
+	r.triggerMill() // the retention config may have changed
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, fmt.Errorf("failed to write to log file: %w", os.ErrClosed)
+	}
+
+	if r.file == nil {
+		if err := r.openLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	// always write at least one entry per file, even if the entry is larger than the max size
+	if r.config.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.config.maxSize {
+		if err := r.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	return r.file.Sync()
+}
+
+// close closes the file and stops the background clean up, see releaseRotatingFile.
+func (r *rotatingFile) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	close(r.mill) // triggerMill is only called while the file is open
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	return nil
+}
+
+func (r *rotatingFile) openLocked() error {
+	if err := os.MkdirAll(filepath.Dir(r.config.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create log file directory: %w", err)
+	}
+
+	file, err := os.OpenFile(r.config.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, r.config.mode)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotateLocked() error {
+	// flush everything written so far before the file is renamed
+	if err := r.file.Sync(); err != nil {
+		return fmt.Errorf("failed to flush log file before rotation: %w", err)
+	}
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file before rotation: %w", err)
+	}
+	r.file = nil
+
+	if err := os.Rename(r.config.path, r.backupName(r.now())); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := r.openLocked(); err != nil {
+		return err
+	}
+
+	r.triggerMill()
+	return nil
+}
+
+func (r *rotatingFile) backupName(t time.Time) string {
+	dir, prefix, ext := r.backupParts()
+	return filepath.Join(dir, prefix+t.UTC().Format(backupTimeFormat)+ext)
+}
+
+func (r *rotatingFile) backupParts() (dir, prefix, ext string) {
+	dir, base := filepath.Split(r.config.path)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+func (r *rotatingFile) triggerMill() {
+	select {
+	case r.mill <- struct{}{}:
+	default: // a run is already pending
+	}
+}
+
+func (r *rotatingFile) millRun() {
+	for range r.mill {
+		r.mu.Lock()
+		config := r.config
+		r.mu.Unlock()
+
+		if err := r.millRunOnce(config); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "mlog: failed to clean up rotated log files: %v\n", err)
+		}
+	}
+}
+
+type backupFile struct {
+	path      string
+	timestamp time.Time
+}
+
+func (r *rotatingFile) millRunOnce(config rotatingFileConfig) error {
+	backups, err := r.backups()
+	if err != nil {
+		return err
+	}
+
+	var remove []backupFile
+	if config.maxBackups > 0 && len(backups) > config.maxBackups {
+		remove = append(remove, backups[config.maxBackups:]...)
+		backups = backups[:config.maxBackups]
+	}
+	if config.maxAge > 0 {
+		cutoff := r.now().Add(-config.maxAge)
+		keep := backups[:0]
+		for _, backup := range backups {
+			if backup.timestamp.Before(cutoff) {
+				remove = append(remove, backup)
+				continue
+			}
+			keep = append(keep, backup)
+		}
+		backups = keep
+	}
+
+	var errs []string
+	for _, backup := range remove {
+		if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if config.compress {
+		for _, backup := range backups {
+			if strings.HasSuffix(backup.path, compressSuffix) {
+				continue
+			}
+			if err := compressFile(backup.path, config.mode); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// backups returns the rotated files sorted from newest to oldest.
+func (r *rotatingFile) backups() ([]backupFile, error) {
+	dir, prefix, ext := r.backupParts()
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil // nothing to clean up, the directory is recreated on the next write
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log file directory: %w", err)
+	}
+
+	var backups []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		timestamp := strings.TrimPrefix(strings.TrimSuffix(strings.TrimSuffix(name, compressSuffix), ext), prefix)
+		t, err := time.Parse(backupTimeFormat, timestamp)
+		if err != nil {
+			continue // not one of our files
+		}
+
+		backups = append(backups, backupFile{path: filepath.Join(dir, name), timestamp: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+
+	return backups, nil
+}
+
+func compressFile(path string, mode os.FileMode) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open rotated log file: %w", err)
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create compressed log file: %w", err)
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + compressSuffix) // do not leave partial files behind
+		return fmt.Errorf("failed to compress rotated log file: %w", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove uncompressed log file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package mlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+func TestFileSpecRotatingFileConfig(t *testing.T) {
+	maxSize := resource.MustParse("10Mi")
+	spec := &FileSpec{
+		Path:       "/var/log/app.log",
+		MaxSize:    &maxSize,
+		MaxAge:     metav1.Duration{Duration: 24 * time.Hour},
+		MaxBackups: 3,
+		Compress:   true,
+		Mode:       0o640,
+	}
+	require.Empty(t, spec.validate(nil))
+
+	config, err := spec.rotatingFileConfig()
+	require.NoError(t, err)
+	require.Equal(t, rotatingFileConfig{
+		path:       "/var/log/app.log",
+		maxSize:    10485760,
+		maxAge:     24 * time.Hour,
+		maxBackups: 3,
+		compress:   true,
+		mode:       0o640,
+	}, config)
+
+	for _, invalid := range []*FileSpec{
+		{},
+		{Path: "app.log", MaxBackups: -1},
+		{Path: "app.log", MaxAge: metav1.Duration{Duration: -time.Second}},
+		{Path: "app.log", Mode: -1},
+	} {
+		require.Len(t, invalid.validate(nil), 1)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	var nowLock sync.Mutex
+	now := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	r := &rotatingFile{
+		now: func() time.Time {
+			nowLock.Lock()
+			defer nowLock.Unlock()
+			now = now.Add(time.Minute) // every rotation gets a unique name
+			return now
+		},
+		config: rotatingFileConfig{path: path, maxSize: 100, mode: defaultFileMode},
+		mill:   make(chan struct{}, 1),
+	}
+
+	const writers, lines = 10, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				_, err := r.Write([]byte(fmt.Sprintf("writer=%02d line=%02d\n", i, j)))
+				require.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, r.Sync())
+
+	backups, err := r.backups()
+	require.NoError(t, err)
+	require.NotEmpty(t, backups)
+
+	// every line is intact and no line is lost or duplicated across the rotated files
+	var got []string
+	for _, f := range append(backups, backupFile{path: path}) {
+		info, err := os.Stat(f.path)
+		require.NoError(t, err)
+		require.LessOrEqual(t, info.Size(), int64(100))
+		require.Equal(t, os.FileMode(defaultFileMode), info.Mode().Perm())
+
+		got = append(got, readLines(t, f.path, false)...)
+	}
+
+	var want []string
+	for i := 0; i < writers; i++ {
+		for j := 0; j < lines; j++ {
+			want = append(want, fmt.Sprintf("writer=%02d line=%02d", i, j))
+		}
+	}
+	sort.Strings(got)
+	require.Equal(t, want, got)
+
+	// only the newest backups are kept, and they are compressed
+	newest := backups[:2]
+	require.NoError(t, r.millRunOnce(rotatingFileConfig{path: path, maxBackups: 2, compress: true, mode: defaultFileMode}))
+
+	after, err := r.backups()
+	require.NoError(t, err)
+	require.Len(t, after, 2)
+	for i, backup := range after {
+		require.Equal(t, newest[i].path+compressSuffix, backup.path)
+		require.Equal(t, newest[i].timestamp, backup.timestamp)
+		require.Len(t, readLines(t, backup.path, true), 5)
+	}
+
+	// everything that is older than max age is removed
+	require.NoError(t, r.millRunOnce(rotatingFileConfig{path: path, maxAge: time.Nanosecond, mode: defaultFileMode}))
+	after, err = r.backups()
+	require.NoError(t, err)
+	require.Empty(t, after)
+}
+
+func TestFileOutput(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested", "app.log")
+	maxSize := resource.MustParse("1Ki")
+
+	require.ErrorIs(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{File: &FileSpec{}}), ErrInvalidLogFile)
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{
+		Level: LevelDebug,
+		File:  &FileSpec{Path: path, MaxSize: &maxSize, MaxBackups: 1},
+	}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	for i := 0; i < 100; i++ {
+		Debug("written to file", "i", i)
+	}
+
+	// the current file and the single retained backup contain the most recent logs
+	dir, base := filepath.Split(path)
+	require.Eventually(t, func() bool {
+		matches, err := filepath.Glob(filepath.Join(dir, strings.TrimSuffix(base, ".log")+"-*.log"))
+		require.NoError(t, err)
+		return len(matches) == 1
+	}, time.Minute, 10*time.Millisecond)
+
+	logs := readLines(t, path, false)
+	require.NotEmpty(t, logs)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(logs[len(logs)-1]), &entry))
+	require.Equal(t, "debug", entry["level"])
+	require.Equal(t, "written to file", entry["message"])
+	require.Equal(t, float64(99), entry["i"])
+}
+
+func TestFileRelease(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{File: &FileSpec{Path: first}}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+	require.Equal(t, 1, rotatingFileRefs(first))
+
+	// loggers with the same rotation config share the file
+	log, err := NewWithConfig(LogSpec{File: &FileSpec{Path: first}})
+	require.NoError(t, err)
+	require.Equal(t, 2, rotatingFileRefs(first))
+	r := rotatingFileFor(first)
+
+	// other loggers cannot change the rotation config of a file that is in use
+	_, err = NewWithConfig(LogSpec{File: &FileSpec{Path: first, MaxBackups: 1}})
+	require.ErrorIs(t, err, errLogFileInUse)
+	require.Equal(t, 2, rotatingFileRefs(first))
+
+	// and neither can the global config while other loggers use it
+	require.ErrorIs(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{File: &FileSpec{Path: first, MaxBackups: 1}}), errLogFileInUse)
+	require.Equal(t, 2, rotatingFileRefs(first))
+
+	// the file stays open after the global config switches to another path
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{File: &FileSpec{Path: second}}))
+	require.Eventually(t, func() bool { return rotatingFileRefs(first) == 1 }, time.Minute, 10*time.Millisecond)
+	require.Equal(t, 1, rotatingFileRefs(second))
+
+	// and is closed once the last logger is closed
+	log.Warning("still open")
+	require.NoError(t, log.Close())
+	require.NoError(t, log.Close())
+	require.Equal(t, 0, rotatingFileRefs(first))
+	require.Equal(t, []string{`"still open"`}, fileMessages(t, first))
+
+	_, err = r.Write([]byte("closed\n"))
+	require.ErrorIs(t, err, os.ErrClosed)
+	_, ok := <-r.mill // millRun has been stopped
+	require.False(t, ok)
+
+	// the global config can change the rotation config of a file that only it uses
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{File: &FileSpec{Path: second, MaxBackups: 1}}))
+	require.Eventually(t, func() bool { return rotatingFileRefs(second) == 1 }, time.Minute, 10*time.Millisecond)
+	require.Equal(t, 1, rotatingFileFor(second).currentConfig().maxBackups)
+
+	// the file is released when the global config no longer uses it, even without a lifecycle
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Format: FormatCLI, File: &FileSpec{Path: second, MaxBackups: 1}}))
+	require.Eventually(t, func() bool { return rotatingFileRefs(second) == 1 }, time.Minute, 10*time.Millisecond)
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Format: FormatCLI}))
+	require.Equal(t, 0, rotatingFileRefs(second))
+}
+
+func rotatingFileFor(path string) *rotatingFile {
+	rotatingFilesLock.Lock()
+	defer rotatingFilesLock.Unlock()
+
+	return rotatingFiles[path]
+}
+
+func rotatingFileRefs(path string) int {
+	rotatingFilesLock.Lock()
+	defer rotatingFilesLock.Unlock()
+
+	if r, ok := rotatingFiles[path]; ok {
+		return r.refs
+	}
+	return 0
+}
+
+func fileMessages(t *testing.T, path string) []string {
+	t.Helper()
+
+	var out []string
+	for _, line := range readLines(t, path, false) {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		out = append(out, fmt.Sprintf("%q", entry["message"]))
+	}
+	return out
+}
+
+func readLines(t *testing.T, path string, compressed bool) []string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, f.Close()) }()
+
+	var r io.Reader = f
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, gz.Close()) }()
+		r = gz
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
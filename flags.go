@@ -0,0 +1,104 @@
+package mlog
+
+import (
+	"context"
+	"encoding"
+	"flag"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const errInvalidLogFormatFlag = constableError("invalid log format, valid choices are the empty string, json, text and cli")
+
+var (
+	_ pflag.Value              = new(LogLevel)
+	_ flag.Value               = new(LogLevel)
+	_ encoding.TextMarshaler   = LevelInfo
+	_ encoding.TextUnmarshaler = new(LogLevel)
+
+	_ pflag.Value              = new(LogFormat)
+	_ flag.Value               = new(LogFormat)
+	_ encoding.TextMarshaler   = FormatJSON
+	_ encoding.TextUnmarshaler = new(LogFormat)
+)
+
+func (l LogLevel) String() string {
+	return string(l)
+}
+
+func (l *LogLevel) Set(s string) error {
+	level, err := parseLogLevel(s)
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+func (LogLevel) Type() string {
+	return "LogLevel"
+}
+
+// MarshalText emits the canonical name of the level.  Invalid levels are emitted as is.
+func (l LogLevel) MarshalText() ([]byte, error) {
+	if level, err := parseLogLevel(string(l)); err == nil {
+		return []byte(level), nil
+	}
+	return []byte(l), nil
+}
+
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	return l.Set(string(text))
+}
+
+func (l LogFormat) String() string {
+	return string(l)
+}
+
+// Set accepts FormatCLI in addition to the formats that are accepted by UnmarshalJSON.
+func (l *LogFormat) Set(s string) error {
+	if LogFormat(s) == FormatCLI {
+		*l = FormatCLI
+		return nil
+	}
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return errInvalidLogFormatFlag
+	}
+	return nil
+}
+
+func (LogFormat) Type() string {
+	return "LogFormat"
+}
+
+func (l LogFormat) MarshalText() ([]byte, error) {
+	return []byte(l), nil
+}
+
+// UnmarshalText uses the same rules as UnmarshalJSON because it is meant for server config, i.e. FormatCLI is rejected.
+func (l *LogFormat) UnmarshalText(text []byte) error {
+	return l.UnmarshalJSON([]byte(strconv.Quote(string(text))))
+}
+
+// AddFlags adds the --log-level and --log-format flags to fs, which are bound to the level and format of spec.
+// The current values of spec are used as the defaults.
+func AddFlags(fs *pflag.FlagSet, spec *LogSpec) {
+	fs.Var(&spec.Level, "log-level", "log level, valid choices are the empty string, info, debug, trace and all")
+	fs.Var(&spec.Format, "log-format", "log format, valid choices are the empty string, json, text and cli")
+}
+
+// PersistentPreRunE returns a function that is meant to be used as a cobra.Command's PersistentPreRunE.
+// It globally applies spec (usually bound to the command's flags via AddFlags) using the command's context.
+// Note that cobra only runs the PersistentPreRunE of the closest command, thus child commands that have
+// their own PersistentPreRunE must call this themselves.
+func PersistentPreRunE(spec *LogSpec) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background() // the command was not executed via ExecuteContext
+		}
+		return ValidateAndSetLogLevelAndFormatGlobally(ctx, *spec)
+	}
+}
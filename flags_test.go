@@ -0,0 +1,113 @@
+package mlog
+
+import (
+	"context"
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestAddFlags(t *testing.T) {
+	spec := LogSpec{Level: LevelInfo}
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	AddFlags(fs, &spec)
+
+	require.Equal(t, "info", fs.Lookup("log-level").DefValue)
+	require.Equal(t, "", fs.Lookup("log-format").DefValue)
+
+	require.NoError(t, fs.Parse([]string{"--log-level=debug", "--log-format", "cli"}))
+	require.Equal(t, LogSpec{Level: LevelDebug, Format: FormatCLI}, spec)
+
+	require.NoError(t, fs.Parse([]string{"--log-level=7"})) // klog style numeric levels are mapped to the nearest level
+	require.Equal(t, LogSpec{Level: LevelTrace, Format: FormatCLI}, spec)
+
+	require.NoError(t, fs.Parse([]string{"--log-level=debug"}))
+
+	err := fs.Parse([]string{"--log-level=panda"})
+	require.EqualError(t, err, `invalid argument "panda" for "--log-level" flag: `+ErrInvalidLogLevel.Error())
+
+	err = fs.Parse([]string{"--log-format=xml"})
+	require.EqualError(t, err, `invalid argument "xml" for "--log-format" flag: `+errInvalidLogFormatFlag.Error())
+
+	require.Equal(t, LogSpec{Level: LevelDebug, Format: FormatCLI}, spec)
+}
+
+func TestFlagValue(t *testing.T) {
+	var level LogLevel
+	var format LogFormat
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&level, "level", "")
+	fs.Var(&format, "format", "")
+
+	require.NoError(t, fs.Parse([]string{"-level", "trace", "-format", "text"}))
+	require.Equal(t, LevelTrace, level)
+	require.Equal(t, FormatText, format)
+}
+
+func TestText(t *testing.T) {
+	text, err := LevelAll.MarshalText()
+	require.NoError(t, err)
+	require.Equal(t, "all", string(text))
+
+	text, err = FormatCLI.MarshalText()
+	require.NoError(t, err)
+	require.Equal(t, "cli", string(text))
+
+	var level LogLevel
+	require.NoError(t, level.UnmarshalText([]byte("debug")))
+	require.Equal(t, LevelDebug, level)
+
+	var format LogFormat
+	require.NoError(t, format.UnmarshalText([]byte("")))
+	require.Equal(t, FormatJSON, format)
+	require.NoError(t, format.UnmarshalText([]byte("text")))
+	require.Equal(t, FormatText, format)
+	require.Equal(t, ErrInvalidLogFormat, format.UnmarshalText([]byte("cli"))) // only allowed on the command line
+}
+
+func TestPersistentPreRunE(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	var spec LogSpec
+	var ran bool
+	cmd := &cobra.Command{
+		Use:               "test",
+		PersistentPreRunE: PersistentPreRunE(&spec),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ran = true
+			require.Equal(t, LogSpec{Level: LevelDebug, Format: FormatCLI}, CurrentSpec())
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	AddFlags(cmd.PersistentFlags(), &spec)
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	cmd.SetArgs([]string{"--log-level", "debug", "--log-format", "cli"})
+	require.NoError(t, cmd.ExecuteContext(ctx))
+	require.True(t, ran)
+	require.True(t, Enabled(LevelDebug))
+}
@@ -5,20 +5,38 @@ import (
 	"fmt"
 	"net/url"
 	"sync"
+	"sync/atomic"
 
 	"github.com/go-logr/logr"
 	"go.uber.org/zap"
 
 	"k8s.io/component-base/logs"
 	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/textlogger"
 )
 
 //nolint:gochecknoglobals
 var (
-	// note that these globals have no locks on purpose - they are expected to be set at init and then again after config parsing.
-	globalLevel  zap.AtomicLevel
-	globalLogger logr.Logger
-	globalFlush  func()
+	// the levels are set at init and are then only changed atomically via SetLevel.
+	globalLevel         zap.AtomicLevel
+	globalKlogSinkLevel zap.AtomicLevel // see LogSpec.KlogLevel
+
+	// the loggers are replaced as a whole whenever the config changes, which may happen while logs are being written.
+	globalLoggers atomic.Pointer[loggers]
+
+	// per logger level overrides are consulted on every log call and thus need to be safe for concurrent use.
+	globalLoggerLevels atomic.Pointer[loggerLevels]
+	globalVModule      atomic.Pointer[vmodule]
+
+	// the most recently applied config, guarded by globalSpecLock because it can be changed at runtime via LevelHandler.
+	globalSpecLock      sync.Mutex
+	globalSpec          LogSpec
+	globalKlogLevel     klog.Level         // the configured level, which may be less verbose than the escalated level
+	globalKlogVerbosity klog.Level         // the verbosity that mlog last set on klog, see reconcileKlogLevelLocked
+	globalEscalations   []*escalation      // see EscalateLevel
+	globalTextConfig    *textlogger.Config // only set when using the text format
+	globalLifecycle     *lifecycle         // only set on servers, see Shutdown
+	globalEarlyBuffer   *earlyBuffer       // only set until the config is applied, see WithEarlyBuffer
 
 	// used as a temporary storage for a buffer per call of newLogr. see the init function below for more details.
 	sinkMap sync.Map
@@ -26,15 +44,23 @@ var (
 
 //nolint:gochecknoinits
 func init() {
+	// stderr is looked up via monis.app-mlog-stderr: so that we control how it is synced.
+	// this must be registered before the default global logger is built below.
+	if err := zap.RegisterSink(stderrSinkScheme, openStderrSink); err != nil {
+		panic(err) // custom sink must always work
+	}
+
 	// make sure we always have a functional global logger
 	globalLevel = zap.NewAtomicLevelAt(0) // log at the 0 verbosity level to start with, i.e. the "always" logs
+	globalKlogSinkLevel = zap.NewAtomicLevelAt(klogSinkLevel(nil))
 	// use json encoding to start with
 	// the context here is just used for test injection and thus can be ignored
-	log, flush, err := newLogr(context.Background(), "json", 0)
+	l, err := newLogr(context.Background(), 0, nil, logOutput{encoding: "json"})
 	if err != nil {
 		panic(err) // default logging config must always work
 	}
-	setGlobalLoggers(log, flush)
+	setGlobalLoggers(l)
+	globalSpec = LogSpec{Format: FormatJSON}
 
 	// this is a little crazy but zap's builder code does not allow us to directly specify what
 	// writer we want to use as our log sink.  to get around this limitation in tests, we use a
@@ -55,21 +81,47 @@ func init() {
 // Deprecated: Use New instead.  This is meant for old code only.
 // New provides a more ergonomic API and correctly responds to global log config change.
 func Logr() logr.Logger {
-	return globalLogger
+	return globalLoggers.Load().log
 }
 
-func Setup() func() {
+func Setup(opts ...SetupOption) func() {
+	var o setupOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	logs.InitLogs()
+
+	if o.earlyBufferSize > 0 {
+		globalSpecLock.Lock()
+		startEarlyBufferLocked(o.earlyBufferSize)
+		globalSpecLock.Unlock()
+	}
+
 	return func() {
 		logs.FlushLogs()
-		globalFlush()
+		dumpEarlyBuffer()                // the config was never applied
+		_ = globalLoggers.Load().flush() // use Shutdown to observe flush errors
 	}
 }
 
-// setGlobalLoggers sets the mlog and klog global loggers.  it is *not* go routine safe.
-func setGlobalLoggers(log logr.Logger, flush func()) {
+// loggers is an immutable snapshot of a logger and the flush and release funcs that belong to it.
+type loggers struct {
+	log     logr.Logger
+	flush   func() error
+	release func() error // closes the log files once the logger is no longer used, safe to call more than once
+}
+
+// close performs a final flush and then releases the log files, reporting any errors to stderr.
+func (l *loggers) close() {
+	reportFlushError(l.flush())
+	reportReleaseError(l.release())
+}
+
+// setGlobalLoggers sets the mlog and klog global loggers.  it is safe to call while logs are being written,
+// but concurrent calls must be serialized by the caller (i.e. by holding globalSpecLock after init).
+func setGlobalLoggers(l *loggers) {
 	// a contextual logger does its own level based enablement checks, which is true for all of our loggers
-	klog.SetLoggerWithOptions(log, klog.ContextualLogger(true), klog.FlushLogger(flush))
-	globalLogger = log
-	globalFlush = flush
+	klog.SetLoggerWithOptions(newKlogLogger(l.log, globalKlogSinkLevel), klog.ContextualLogger(true), klog.FlushLogger(func() { _ = l.flush() }))
+	globalLoggers.Store(l)
 }
@@ -0,0 +1,67 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestConcurrentConfigChange(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+		require.NoError(t, Shutdown(context.Background()))
+	})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				Info("from mlog", "panda", 1)
+				Logr().Info("from logr")
+				New().WithName("bear").Debug("from named")
+				klog.InfoS("from klog")
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		format := FormatJSON
+		if i%2 == 1 {
+			format = FormatCLI
+		}
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelDebug, Format: format}))
+	}
+
+	require.Eventually(t, func() bool {
+		return bytes.Contains(buf.Bytes(), []byte("from mlog"))
+	}, time.Minute, time.Millisecond)
+
+	close(done)
+	wg.Wait()
+}
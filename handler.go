@@ -0,0 +1,116 @@
+package mlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const errRuntimeSpecChange = constableError("only level and loggerLevels can be changed at runtime")
+
+// LevelHandler returns an http.Handler that can be used to inspect and change the global log level at runtime.
+// A GET request responds with the effective LogSpec as JSON, see CurrentSpec.  A PUT request with a LogSpec JSON
+// body changes the configured level (and per logger levels) without rebuilding any loggers, and responds with the
+// updated effective LogSpec.  Active escalations via EscalateLevel continue to apply on top of the new level.
+// All other fields of the LogSpec, such as the format, cannot be changed at runtime and thus must be either
+// omitted or match the current config.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeSpec(w, CurrentSpec())
+
+		case http.MethodPut:
+			var spec LogSpec
+			decoder := json.NewDecoder(r.Body)
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&spec); err != nil {
+				http.Error(w, fmt.Sprintf("invalid log spec: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			updated, err := setLevelFromSpec(spec)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid log spec: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			writeSpec(w, updated)
+
+		default:
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// CurrentSpec returns a copy of the effective LogSpec, i.e. ConfiguredSpec with the level raised to the
+// most verbose active escalation via EscalateLevel, if any.
+func CurrentSpec() LogSpec {
+	globalSpecLock.Lock()
+	defer globalSpecLock.Unlock()
+
+	return effectiveSpecLocked()
+}
+
+// ConfiguredSpec returns a copy of the configured LogSpec, i.e. the most recently applied LogSpec with defaults
+// filled in, including any runtime level changes made via LevelHandler or the level signals.  Temporary
+// escalations via EscalateLevel are not included, see CurrentSpec.
+func ConfiguredSpec() LogSpec {
+	globalSpecLock.Lock()
+	defer globalSpecLock.Unlock()
+
+	return globalSpec.deepCopy()
+}
+
+func effectiveSpecLocked() LogSpec {
+	spec := globalSpec.deepCopy()
+	if klogLevel := effectiveKlogLevel(); klogLevel > globalKlogLevel {
+		spec.Level = zapLevelToMlogLevel(zapcore.Level(-klogLevel))
+	}
+	return spec
+}
+
+func setLevelFromSpec(spec LogSpec) (LogSpec, error) {
+	if err := spec.validate(nil).err(); err != nil {
+		return LogSpec{}, err
+	}
+
+	klogLevel := klogLevelForMlogLevel(spec.Level)
+	levels, err := newLoggerLevels(spec.LoggerLevels)
+	if err != nil {
+		return LogSpec{}, err
+	}
+
+	globalSpecLock.Lock()
+	defer globalSpecLock.Unlock()
+
+	old := globalSpec
+
+	// everything other than the levels requires rebuilding the loggers and thus must be omitted or unchanged
+	rest, oldRest := spec.deepCopy(), old.deepCopy()
+	rest.Level, rest.LoggerLevels = "", nil
+	oldRest.Level, oldRest.LoggerLevels = "", nil
+	if !reflect.DeepEqual(rest, LogSpec{}) && !reflect.DeepEqual(rest, oldRest) {
+		return LogSpec{}, errRuntimeSpecChange
+	}
+
+	updated := old.deepCopy()
+	updated.Level = spec.Level
+	updated.LoggerLevels = spec.LoggerLevels
+
+	setLevelGlobally(klogLevel, levels)
+	globalSpec = updated.deepCopy()
+
+	Always("log level changed at runtime", "old", old, "new", updated)
+
+	return effectiveSpecLocked(), nil
+}
+
+func writeSpec(w http.ResponseWriter, spec LogSpec) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(spec)
+}
@@ -0,0 +1,121 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestLevelHandler(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelInfo}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{}))
+	})
+
+	server := httptest.NewServer(LevelHandler())
+	t.Cleanup(server.Close)
+
+	do := func(method, body string) (int, string) {
+		t.Helper()
+
+		req, err := http.NewRequestWithContext(ctx, method, server.URL, strings.NewReader(body))
+		require.NoError(t, err)
+
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		var out strings.Builder
+		_, err = io.Copy(&out, resp.Body)
+		require.NoError(t, err)
+
+		return resp.StatusCode, strings.TrimSpace(out.String())
+	}
+
+	code, body := do(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"level":"info","format":"json"}`, body)
+
+	code, body = do(http.MethodPut, `{"level":"debug","loggerLevels":{"leader":""}}`)
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"level":"debug","format":"json","loggerLevels":{"leader":""}}`, body)
+	require.Equal(t, klog.Level(4), getKlogLevel())
+	require.True(t, Enabled(LevelDebug))
+	require.False(t, Enabled(LevelTrace))
+	require.Contains(t, string(buf.Bytes()), `"message":"log level changed at runtime"`)
+
+	code, body = do(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"level":"debug","format":"json","loggerLevels":{"leader":""}}`, body)
+
+	code, body = do(http.MethodPut, `{"level":"panda"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, "invalid log spec: "+ErrInvalidLogLevel.Error(), body)
+
+	code, body = do(http.MethodPut, `{"level":"info","format":"text"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, "invalid log spec: "+errRuntimeSpecChange.Error(), body)
+
+	code, body = do(http.MethodPut, `{"level":"info","file":{"path":"/var/log/mlog.log"}}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, "invalid log spec: "+errRuntimeSpecChange.Error(), body)
+
+	code, body = do(http.MethodPut, `{"level":"debug","format":"json","loggerLevels":{"leader":""}}`)
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"level":"debug","format":"json","loggerLevels":{"leader":""}}`, body)
+
+	code, body = do(http.MethodPut, `{"levle":"info"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, `invalid log spec: json: unknown field "levle"`, body)
+
+	code, _ = do(http.MethodPost, `{}`)
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+
+	// failed requests must not change anything
+	require.Equal(t, klog.Level(4), getKlogLevel())
+	require.Equal(t, LogSpec{Level: LevelDebug, Format: FormatJSON, LoggerLevels: map[string]LogLevel{"leader": ""}}, CurrentSpec())
+
+	// escalations are included in the effective level but do not change the configured level
+	escalateCtx, escalateCancel := context.WithCancel(ctx)
+	t.Cleanup(escalateCancel)
+	require.NoError(t, EscalateLevel(escalateCtx, LevelTrace, time.Hour))
+
+	code, body = do(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"level":"trace","format":"json","loggerLevels":{"leader":""}}`, body)
+
+	code, body = do(http.MethodPut, `{"level":"info"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"level":"trace","format":"json"}`, body)
+	require.Equal(t, LogSpec{Level: LevelInfo, Format: FormatJSON}, ConfiguredSpec())
+
+	escalateCancel()
+	require.Eventually(t, func() bool {
+		return bytes.Contains(buf.Bytes(), []byte(`"message":"log level escalation reverted"`))
+	}, time.Minute, 10*time.Millisecond)
+
+	code, body = do(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"level":"info","format":"json"}`, body)
+}
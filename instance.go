@@ -0,0 +1,148 @@
+package mlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ClosableLogger is a Logger with its own configuration, see NewWithConfig.
+type ClosableLogger interface {
+	Logger
+
+	// Close stops the periodic flushing of logs, performs a final flush and then closes any log files that
+	// are not used by other loggers.  Loggers derived via WithValues and WithName remain usable after Close,
+	// but are no longer flushed in the background and fail to write to log files.
+	Close() error
+}
+
+// ConfigOption configures NewWithConfig.
+type ConfigOption func(*configOptions)
+
+type configOptions struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// WithContext stops the periodic flushing of logs once ctx is done.  It is also used to inject test overrides,
+// see TestZapOverrides.
+func WithContext(ctx context.Context) ConfigOption {
+	return func(o *configOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithWriter sends logs that would otherwise go to stderr to w instead.  Outputs with a file are not affected.
+func WithWriter(w io.Writer) ConfigOption {
+	return func(o *configOptions) {
+		o.w = w
+	}
+}
+
+var _ ClosableLogger = &configuredLogger{}
+
+type configuredLogger struct {
+	mLogger
+
+	loggers   *loggers
+	lifecycle *lifecycle // only set when logs are flushed in the background
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewWithConfig returns a Logger that is configured via spec instead of the global config.  Unlike the
+// Logger returned by New, it does not change when ValidateAndSetLogLevelAndFormatGlobally is called and
+// it never changes the global config (including klog's).  This allows libraries and tests to log to
+// their own outputs at their own levels.  The deprecated text format is not supported.  Just like the
+// global config, logs are flushed in the background unless spec.Format is FormatCLI.  Close should be
+// called once the Logger is no longer needed.
+func NewWithConfig(spec LogSpec, opts ...ConfigOption) (ClosableLogger, error) {
+	o := configOptions{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	v := spec.validate(nil)
+	if spec.Format == FormatText {
+		v.add(ErrInvalidLogFormat, field.Forbidden(field.NewPath("format"), "the text format is not supported by NewWithConfig"))
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	levels, err := newLoggerLevels(spec.LoggerLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	level := zap.NewAtomicLevelAt(zapcore.Level(-klogLevelForMlogLevel(spec.Level))) // klog levels are inverted when zap handles them
+	atLeast := func(l LogLevel) zapcore.LevelEnabler {
+		return zap.LevelEnablerFunc(func(zapcore.Level) bool {
+			return level.Enabled(zapcore.Level(-klogLevelForMlogLevel(l))) // same as LogLevel.Enabled but for this logger's level
+		})
+	}
+	zl := zapLevels{
+		level:    level,
+		levels:   &atomic.Pointer[loggerLevels]{},
+		vmodule:  &atomic.Pointer[vmodule]{},
+		addStack: spec.Stacktrace.enabler(atLeast),
+		trace:    atLeast(LevelTrace),
+	}
+	zl.levels.Store(&levels)
+	zl.vmodule.Store(newVModule(spec.VModule))
+
+	encoding := "json"
+	sampler := spec.Sampling.sampler()
+	cliSampling := spec.Format == FormatCLI && sampler != nil
+	if spec.Format == FormatCLI {
+		encoding = "console"
+		sampler = nil // the summary of dropped logs is never logged without a lifecycle, same as the global config
+	}
+
+	l, err := newOutputsLogr(o.ctx, zl, o.w, sampler, false, spec.logOutputs(encoding)...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &configuredLogger{mLogger: mLogger{log: &l.log}, loggers: l}
+	if sampler != nil {
+		sampler.log = out.mLogger
+	}
+
+	if spec.Format != FormatCLI {
+		flushInterval := defaultFlushInterval
+		if spec.FlushInterval != nil && spec.FlushInterval.Duration > 0 {
+			flushInterval = spec.FlushInterval.Duration
+		}
+		out.lifecycle = startLifecycle(o.ctx, flushInterval, l, sampler, nil) // only the global config owns klog's verbosity
+	}
+
+	if cliSampling {
+		out.Warning("log.sampling is ignored when log.format is 'cli'")
+	}
+
+	return out, nil
+}
+
+func (c *configuredLogger) Close() error {
+	c.closeOnce.Do(func() {
+		if c.lifecycle != nil {
+			_ = c.lifecycle.stop(context.Background()) // cannot fail without a deadline, the go routine only blocks on flushing
+		}
+		if err := c.loggers.flush(); err != nil {
+			c.closeErr = fmt.Errorf("failed to flush logs: %w", err)
+		}
+		if err := c.loggers.release(); err != nil && c.closeErr == nil {
+			c.closeErr = fmt.Errorf("failed to close log files: %w", err)
+		}
+	})
+	return c.closeErr
+}
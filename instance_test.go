@@ -0,0 +1,92 @@
+package mlog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestNewWithConfig(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	globalSpecBefore := CurrentSpec()
+
+	var jsonBuf syncBuffer
+	jsonLog, err := NewWithConfig(LogSpec{
+		Level:        LevelDebug,
+		LoggerLevels: map[string]LogLevel{"webhook": LevelTrace},
+	}, WithWriter(&jsonBuf))
+	require.NoError(t, err)
+
+	var cliBuf syncBuffer
+	cliLog, err := NewWithConfig(LogSpec{Format: FormatCLI}, WithWriter(&cliBuf))
+	require.NoError(t, err)
+
+	// the global config is not changed
+	require.Equal(t, globalSpecBefore, CurrentSpec())
+	require.False(t, Enabled(LevelDebug))
+
+	for _, log := range []Logger{jsonLog, cliLog} {
+		log.Debug("debug", "panda", 1)
+		log.WithValues("bear", 2).Warning("warning")
+		log.WithName("webhook").Trace("trace")
+		log.Error("error", errors.New("oops"))
+	}
+
+	// changing the global config does not change the independent loggers
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{Level: LevelAll}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+		require.NoError(t, Shutdown(context.Background()))
+	})
+	cliLog.Info("info")
+
+	lines := jsonLines(t, jsonBuf.Bytes())
+	require.Len(t, lines, 4)
+	require.Equal(t, "debug", lines[0]["message"])
+	require.Equal(t, float64(1), lines[0]["panda"])
+	require.Equal(t, "warning", lines[1]["message"])
+	require.Equal(t, float64(2), lines[1]["bear"])
+	require.Equal(t, "trace", lines[2]["message"])
+	require.Equal(t, "webhook", lines[2]["logger"])
+	require.Equal(t, "error", lines[3]["message"])
+	require.Contains(t, lines[3]["caller"], "instance_test.go:")
+	require.Nil(t, lines[3]["stacktrace"], "stack traces require the trace level")
+
+	cliLines := strings.Split(strings.TrimSpace(string(cliBuf.Bytes())), "\n")
+	require.Len(t, cliLines, 2)
+	require.Contains(t, cliLines[0], "  warning  ")
+	require.Contains(t, cliLines[0], `{"bear": 2}`)
+	require.Contains(t, cliLines[1], "error")
+	require.Contains(t, cliLines[1], `{"error": "oops"}`)
+
+	// close performs a final flush and can be called more than once
+	syncs := jsonBuf.syncs.Load()
+	require.NoError(t, jsonLog.Close())
+	require.Equal(t, syncs+1, jsonBuf.syncs.Load())
+	require.NoError(t, jsonLog.Close())
+	require.Equal(t, syncs+1, jsonBuf.syncs.Load())
+	require.NoError(t, cliLog.Close())
+
+	var failBuf syncBuffer
+	failLog, err := NewWithConfig(LogSpec{}, WithWriter(&failBuf))
+	require.NoError(t, err)
+	failBuf.fail.Store(true)
+	require.EqualError(t, failLog.Close(), "failed to flush logs: sync failed")
+
+	_, err = NewWithConfig(LogSpec{Format: FormatText})
+	require.EqualError(t, err, "format: Forbidden: the text format is not supported by NewWithConfig")
+	require.ErrorIs(t, err, ErrInvalidLogFormat)
+
+	_, err = NewWithConfig(LogSpec{Level: "panda"})
+	require.ErrorIs(t, err, ErrInvalidLogLevel)
+}
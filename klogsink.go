@@ -0,0 +1,65 @@
+package mlog
+
+import (
+	"math"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap/zapcore"
+)
+
+// klogSinkLevel returns the zap level that klog based logs are restricted to, see LogSpec.KlogLevel.
+func klogSinkLevel(level *LogLevel) zapcore.Level {
+	if level == nil {
+		return math.MinInt8 // no further restrictions beyond the global level
+	}
+	return zapcore.Level(-klogLevelForMlogLevel(*level)) // klog levels are inverted when zap handles them
+}
+
+// newKlogLogger returns a logger for klog that shares log's outputs but further restricts its verbosity.
+// this allows logs from kube libraries to be distinguished from the logs that are emitted via mLogger.
+func newKlogLogger(log logr.Logger, level zapcore.LevelEnabler) logr.Logger {
+	sink := log.GetSink()
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(1) // account for the extra frame from klogSink
+	}
+	return logr.New(&klogSink{sink: sink, level: level})
+}
+
+var _ logr.CallDepthLogSink = &klogSink{}
+
+type klogSink struct {
+	sink  logr.LogSink
+	level zapcore.LevelEnabler
+}
+
+func (k *klogSink) Init(logr.RuntimeInfo) {
+	// the wrapped sink was already initialized by its logger and is adjusted for our call depth by newKlogLogger
+}
+
+func (k *klogSink) Enabled(level int) bool {
+	return k.level.Enabled(zapcore.Level(-level)) && k.sink.Enabled(level) // klog levels are inverted when zap handles them
+}
+
+func (k *klogSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	k.sink.Info(level, msg, keysAndValues...)
+}
+
+func (k *klogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	k.sink.Error(err, msg, keysAndValues...)
+}
+
+func (k *klogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &klogSink{sink: k.sink.WithValues(keysAndValues...), level: k.level}
+}
+
+func (k *klogSink) WithName(name string) logr.LogSink {
+	return &klogSink{sink: k.sink.WithName(name), level: k.level}
+}
+
+func (k *klogSink) WithCallDepth(depth int) logr.LogSink {
+	withCallDepth, ok := k.sink.(logr.CallDepthLogSink)
+	if !ok {
+		return k
+	}
+	return &klogSink{sink: withCallDepth.WithCallDepth(depth), level: k.level}
+}
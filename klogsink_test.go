@@ -0,0 +1,79 @@
+package mlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestKlogLevel(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelDebug, KlogLevel: levelPtr(LevelInfo)}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+		require.NoError(t, Shutdown(context.Background()))
+	})
+
+	Debug("mlog debug")
+	klog.V(klogLevelInfo).InfoS("klog info")
+	klog.V(klogLevelDebug).InfoS("klog debug")
+	klog.Background().V(klogLevelDebug).Info("contextual debug")
+	klog.Background().WithName("client").V(klogLevelInfo).Info("contextual info")
+	klog.ErrorS(errors.New("oops"), "klog error")
+	klog.InfoS("klog user data", "warning", true) // only mLogger.Warning can mark a warning
+
+	lines := jsonLines(t, buf.Bytes())
+	require.Len(t, lines, 5)
+	require.Equal(t, "mlog debug", lines[0]["message"])
+	require.Equal(t, "klog info", lines[1]["message"])
+	require.Equal(t, "contextual info", lines[2]["message"])
+	require.Equal(t, "client", lines[2]["logger"])
+	require.Equal(t, "klog error", lines[3]["message"])
+	require.Equal(t, "klog user data", lines[4]["message"])
+	require.Equal(t, "info", lines[4]["level"])
+	require.Equal(t, true, lines[4]["warning"])
+	for _, line := range lines {
+		require.Contains(t, line["caller"], "klogsink_test.go:", "the caller must not include the klog sink")
+	}
+
+	require.Equal(t, levelPtr(LevelInfo), CurrentSpec().KlogLevel)
+
+	// the klog level cannot be more verbose than the global level
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelInfo, KlogLevel: levelPtr(LevelTrace)}))
+	offset := len(buf.Bytes())
+	klog.V(klogLevelDebug).InfoS("klog debug")
+	klog.V(klogLevelInfo).InfoS("klog info")
+	lines = jsonLines(t, buf.Bytes()[offset:])
+	require.Len(t, lines, 1)
+	require.Equal(t, "klog info", lines[0]["message"])
+
+	// klog based logs can be restricted to warnings and errors, i.e. to the logs at klog verbosity 0
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelInfo, KlogLevel: levelPtr(LevelWarning)}))
+	offset = len(buf.Bytes())
+	klog.V(klogLevelInfo).InfoS("klog info")
+	klog.ErrorS(errors.New("oops"), "klog error")
+	Info("mlog info")
+	lines = jsonLines(t, buf.Bytes()[offset:])
+	require.Len(t, lines, 2)
+	require.Equal(t, "klog error", lines[0]["message"])
+	require.Equal(t, "mlog info", lines[1]["message"])
+
+	err := ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{KlogLevel: levelPtr("panda")})
+	require.EqualError(t, err, `klogLevel: Unsupported value: "panda": supported values: "", "info", "debug", "trace", "all"`)
+	require.ErrorIs(t, err, ErrInvalidLogLevel)
+}
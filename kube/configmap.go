@@ -0,0 +1,86 @@
+// Package kube keeps the mlog config in sync with a Kubernetes ConfigMap.  It is a separate module so that
+// only callers that use it depend on client-go.
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	"monis.app/mlog"
+)
+
+// WatchConfigMap keeps the global log config in sync with the LogSpec stored as JSON under key in the
+// ConfigMap namespace/name.  Valid changes are applied globally via mlog.ReloadLogSpecGlobally
+// while invalid changes are logged as a warning and ignored, i.e. the previous config remains active.
+// If recorder is non-nil, rejected changes are also reported as a Warning event on the ConfigMap.
+// Deleting the ConfigMap or the key leaves the current config unchanged.  WatchConfigMap blocks until ctx is done.
+// Cancelling ctx stops the watch but not the background flushing of the reloaded config, see mlog.ReloadLogSpecGlobally.
+func WatchConfigMap(ctx context.Context, client kubernetes.Interface, namespace, name, key string, recorder record.EventRecorder) {
+	informer := coreinformers.NewFilteredConfigMapInformer(client, namespace, 0, cache.Indexers{},
+		func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		},
+	)
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			syncConfigMap(ctx, obj, key, recorder)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			syncConfigMap(ctx, obj, key, recorder)
+		},
+	})
+
+	informer.Run(ctx.Done()) // waits for all of the informer's go routines to exit
+}
+
+func syncConfigMap(ctx context.Context, obj interface{}, key string, recorder record.EventRecorder) {
+	configMap, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return
+	}
+
+	data, ok := configMap.Data[key]
+	if !ok {
+		mlog.Warning("log config key missing from config map, previous config remains active",
+			"configMap", klog.KObj(configMap), "key", key)
+		return
+	}
+
+	var spec mlog.LogSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		rejectConfigMap(configMap, key, recorder, err)
+		return
+	}
+
+	old, updated, err := mlog.ReloadLogSpecGlobally(ctx, spec)
+	if err != nil {
+		rejectConfigMap(configMap, key, recorder, err)
+		return
+	}
+
+	if !reflect.DeepEqual(old, updated) {
+		mlog.Always("log config reloaded from config map", "old", old, "new", updated,
+			"configMap", klog.KObj(configMap), "key", key)
+	}
+}
+
+func rejectConfigMap(configMap *corev1.ConfigMap, key string, recorder record.EventRecorder, err error) {
+	mlog.WarningErr("rejected invalid log config from config map, previous config remains active", err,
+		"configMap", klog.KObj(configMap), "key", key, "current", mlog.ConfiguredSpec())
+
+	if recorder != nil {
+		recorder.Event(configMap, corev1.EventTypeWarning, "InvalidLogConfig",
+			fmt.Sprintf("rejected invalid log config in key %q: %v", key, err))
+	}
+}
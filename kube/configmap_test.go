@@ -0,0 +1,124 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/logs"
+	"k8s.io/klog/v2"
+
+	"monis.app/mlog"
+)
+
+func TestWatchConfigMap(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = mlog.TestZapOverrides(ctx, t, &buf, nil)
+
+	require.NoError(t, mlog.ValidateAndSetLogLevelAndFormatGlobally(ctx, mlog.LogSpec{Level: mlog.LevelInfo}))
+	t.Cleanup(func() {
+		require.NoError(t, mlog.ValidateAndSetLogLevelAndFormatGlobally(context.Background(), mlog.LogSpec{}))
+	})
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "log-config"},
+		Data:       map[string]string{"log.json": `{"level":"debug"}`},
+	}
+	client := fake.NewSimpleClientset(configMap)
+	recorder := record.NewFakeRecorder(10)
+
+	watchCtx, watchCancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WatchConfigMap(watchCtx, client, "ns", "log-config", "log.json", recorder)
+	}()
+	t.Cleanup(func() {
+		watchCancel()
+		<-done
+	})
+
+	require.Eventually(t, func() bool {
+		return bytes.Contains(buf.Bytes(), []byte(`"message":"log config reloaded from config map"`))
+	}, time.Minute, 10*time.Millisecond)
+	require.Equal(t, mlog.LogSpec{Level: mlog.LevelDebug, Format: mlog.FormatJSON}, mlog.CurrentSpec())
+	require.Equal(t, klog.Level(4), getKlogLevel())
+
+	update := func(data string) {
+		t.Helper()
+
+		configMap = configMap.DeepCopy()
+		configMap.Data["log.json"] = data
+		_, err := client.CoreV1().ConfigMaps("ns").Update(ctx, configMap, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+
+	update(`{"level":"panda"}`)
+	require.Equal(t, `Warning InvalidLogConfig rejected invalid log config in key "log.json": `+mlog.ErrInvalidLogLevel.Error(), <-recorder.Events)
+	require.Contains(t, string(buf.Bytes()), `"message":"rejected invalid log config from config map, previous config remains active"`)
+	require.Equal(t, mlog.LogSpec{Level: mlog.LevelDebug, Format: mlog.FormatJSON}, mlog.CurrentSpec())
+
+	update(`{"format":"cli"}`)
+	require.Equal(t, `Warning InvalidLogConfig rejected invalid log config in key "log.json": `+mlog.ErrInvalidLogFormat.Error(), <-recorder.Events)
+	require.Equal(t, mlog.LogSpec{Level: mlog.LevelDebug, Format: mlog.FormatJSON}, mlog.CurrentSpec())
+
+	update(`{"level":"trace","loggerLevels":{"leader":""}}`)
+	require.Eventually(t, func() bool {
+		return mlog.CurrentSpec().Level == mlog.LevelTrace
+	}, time.Minute, 10*time.Millisecond)
+	require.Equal(t, mlog.LogSpec{Level: mlog.LevelTrace, Format: mlog.FormatJSON, LoggerLevels: map[string]mlog.LogLevel{"leader": ""}}, mlog.CurrentSpec())
+	require.Equal(t, klog.Level(6), getKlogLevel())
+}
+
+func undoGlobalLogLevelChanges(t *testing.T, originalLogLevel klog.Level) {
+	t.Helper()
+	_, err := logs.GlogSetter(strconv.Itoa(int(originalLogLevel)))
+	require.NoError(t, err)
+}
+
+func getKlogLevel() klog.Level {
+	// hack around klog not exposing a Get method
+	for i := klog.Level(0); i < 256; i++ {
+		if klog.V(i).Enabled() {
+			continue
+		}
+		return i - 1
+	}
+
+	return -1
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) Bytes() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte(nil), s.buf.Bytes()...)
+}
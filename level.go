@@ -1,6 +1,12 @@
 package mlog
 
 import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
 	"go.uber.org/zap/zapcore"
 
 	"k8s.io/klog/v2"
@@ -8,8 +14,52 @@ import (
 
 // LogLevel is an enum that controls verbosity of logs.
 // Valid values in order of increasing verbosity are leaving it unset, info, debug, trace and all.
+// When parsed, numeric klog levels are also accepted and are mapped onto the nearest less verbose level,
+// i.e. 5 is parsed as debug.
 type LogLevel string
 
+var _ json.Unmarshaler = func() *LogLevel {
+	var l LogLevel
+	return &l
+}()
+
+func (l *LogLevel) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+
+	s := string(b) // numeric klog levels may be passed as JSON numbers
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return ErrInvalidLogLevel
+		}
+	}
+
+	level, err := parseLogLevel(s)
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// parseLogLevel returns the canonical LogLevel for s, which is either a level name or a numeric klog level.
+func parseLogLevel(s string) (LogLevel, error) {
+	if level := LogLevel(s); klogLevelForMlogLevel(level) >= 0 {
+		return level, nil
+	}
+
+	klogLevel, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return "", ErrInvalidLogLevel
+	}
+	if klogLevel > klogLevelAll {
+		klogLevel = klogLevelAll // prevent overflow of the zap level
+	}
+
+	return zapLevelToMlogLevel(zapcore.Level(-int8(klogLevel))), nil // klog levels are inverted when zap handles them
+}
+
 func (l LogLevel) Enabled(_ zapcore.Level) bool {
 	return Enabled(l) // this basically says "log if the global mlog level is l or greater"
 }
@@ -41,9 +91,28 @@ const (
 // provided level will show up.
 func Enabled(level LogLevel) bool {
 	l := klogLevelForMlogLevel(level)
-	// check that both our global level and the klog global level agree that the mlog level is enabled
+	// mlog owns the verbosity and keeps klog in sync with it, see reconcileKlogLevelLocked
 	// klog levels are inverted when zap handles them
-	return globalLevel.Enabled(zapcore.Level(-l)) && klog.V(l).Enabled()
+	return globalLevel.Enabled(zapcore.Level(-l))
+}
+
+// reconcileKlogLevelLocked detects changes to klog's verbosity that were not made via mlog, i.e. by a
+// dependency or a -v flag, and resets klog's verbosity to match mlog's.  the caller must hold globalSpecLock.
+func reconcileKlogLevelLocked() {
+	if klogVerbosity() != globalKlogVerbosity {
+		updateLevelGlobally() // warns about the change before resetting it
+	}
+}
+
+// klogVerbosity returns klog's global verbosity, which is capped at math.MaxInt8 (since that is all we ever set).
+func klogVerbosity() klog.Level {
+	// hack around klog not exposing a Get method
+	for i := klog.Level(0); i <= math.MaxInt8; i++ {
+		if !klog.V(i).Enabled() {
+			return i - 1
+		}
+	}
+	return math.MaxInt8
 }
 
 func klogLevelForMlogLevel(mlogLevel LogLevel) klog.Level {
@@ -62,3 +131,58 @@ func klogLevelForMlogLevel(mlogLevel LogLevel) klog.Level {
 		return -1
 	}
 }
+
+// loggerLevels holds per logger level overrides keyed by logger name prefix.
+// It is sorted so that the most specific (i.e. longest) prefix comes first.
+type loggerLevels []loggerLevel
+
+type loggerLevel struct {
+	prefix string
+	level  zapcore.Level
+}
+
+func newLoggerLevels(levels map[string]LogLevel) (loggerLevels, error) {
+	if len(levels) == 0 {
+		return nil, nil
+	}
+
+	out := make(loggerLevels, 0, len(levels))
+	for prefix, level := range levels {
+		klogLevel := klogLevelForMlogLevel(level)
+		if klogLevel < 0 {
+			return nil, ErrInvalidLogLevel
+		}
+		out = append(out, loggerLevel{
+			prefix: prefix,
+			level:  zapcore.Level(-klogLevel), // klog levels are inverted when zap handles them
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return len(out[i].prefix) > len(out[j].prefix)
+	})
+
+	return out, nil
+}
+
+// levelFor returns the level of the most specific override that applies to the logger with the given name.
+// Logger names are built up via WithName and are joined with a dot, i.e. a prefix of webhook matches
+// loggers named webhook and webhook.tokenexchange but not webhooks.
+func (l loggerLevels) levelFor(name string) (zapcore.Level, bool) {
+	for _, ll := range l {
+		if len(ll.prefix) == 0 || name == ll.prefix || strings.HasPrefix(name, ll.prefix+".") {
+			return ll.level, true
+		}
+	}
+	return 0, false
+}
+
+// enabled returns whether any override would allow the provided level to be logged.
+func (l loggerLevels) enabled(level zapcore.Level) bool {
+	for _, ll := range l {
+		if ll.level.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
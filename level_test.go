@@ -0,0 +1,134 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/component-base/logs"
+	"k8s.io/klog/v2"
+)
+
+func TestLogLevelUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    LogLevel
+		wantErr error
+	}{
+		{in: `""`, want: LevelWarning},
+		{in: `"info"`, want: LevelInfo},
+		{in: `"debug"`, want: LevelDebug},
+		{in: `"trace"`, want: LevelTrace},
+		{in: `"all"`, want: LevelAll},
+		{in: `"0"`, want: LevelWarning},
+		{in: `1`, want: LevelWarning},
+		{in: `"2"`, want: LevelInfo},
+		{in: `3`, want: LevelInfo},
+		{in: `4`, want: LevelDebug},
+		{in: `"5"`, want: LevelDebug},
+		{in: `6`, want: LevelTrace},
+		{in: `8`, want: LevelAll},
+		{in: `"100"`, want: LevelAll},
+		{in: `4294967295`, want: LevelAll},
+		{in: `null`, want: LevelWarning},
+		{in: `"panda"`, wantErr: ErrInvalidLogLevel},
+		{in: `-1`, wantErr: ErrInvalidLogLevel},
+		{in: `"-4"`, wantErr: ErrInvalidLogLevel},
+		{in: `4.5`, wantErr: ErrInvalidLogLevel},
+		{in: `4294967296`, wantErr: ErrInvalidLogLevel},
+		{in: `"DEBUG"`, wantErr: ErrInvalidLogLevel},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.in, func(t *testing.T) {
+			var level LogLevel
+			err := json.Unmarshal([]byte(tt.in), &level)
+			require.Equal(t, tt.wantErr, err)
+			require.Equal(t, tt.want, level)
+		})
+	}
+}
+
+func TestLogLevelRoundTrip(t *testing.T) {
+	var spec LogSpec
+	require.NoError(t, json.Unmarshal([]byte(`{"level":4,"loggerLevels":{"webhook":"6","leader":0}}`), &spec))
+	require.Equal(t, LogSpec{Level: LevelDebug, LoggerLevels: map[string]LogLevel{"webhook": LevelTrace, "leader": LevelWarning}}, spec)
+
+	data, err := json.Marshal(spec)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"level":"debug","loggerLevels":{"webhook":"trace","leader":""}}`, string(data))
+
+	// levels that were not parsed are still marshaled using their canonical name
+	data, err = json.Marshal(LogSpec{Level: "2"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"level":"info"}`, string(data))
+
+	err = json.Unmarshal([]byte(`{"level":"panda"}`), &spec)
+	require.Equal(t, ErrInvalidLogLevel, err)
+}
+
+func TestKlogLevelReconcile(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelDebug, FlushInterval: &metav1.Duration{Duration: time.Hour}}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+		require.NoError(t, Shutdown(context.Background()))
+	})
+	require.Equal(t, klog.Level(klogLevelDebug), getKlogLevel())
+
+	// mlog's view does not change when klog's verbosity is changed by someone else
+	_, err := logs.GlogSetter("0")
+	require.NoError(t, err)
+	require.True(t, Enabled(LevelDebug))
+	require.False(t, Enabled(LevelTrace))
+
+	reconcileKlogLevel()
+	require.Equal(t, klog.Level(klogLevelDebug), getKlogLevel())
+	require.Contains(t, string(buf.Bytes()), `"message":"klog verbosity was changed outside of mlog and has been reset, use the log level config instead","klogLevel":0,"expectedKlogLevel":4}`)
+	require.Equal(t, LogSpec{Level: LevelDebug, Format: FormatJSON, FlushInterval: &metav1.Duration{Duration: time.Hour}}, CurrentSpec())
+
+	// nothing to do when klog is in sync
+	reconcileKlogLevel()
+	require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("klog verbosity was changed")))
+
+	// changes are detected whenever mlog applies levels, even on the CLI which has no background reconciliation
+	_, err = logs.GlogSetter("7")
+	require.NoError(t, err)
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelDebug, Format: FormatCLI}))
+	require.Equal(t, klog.Level(klogLevelDebug), getKlogLevel())
+	require.Contains(t, string(buf.Bytes()), `klog verbosity was changed outside of mlog and has been reset, use the log level config instead  {"klogLevel": 7, "expectedKlogLevel": 4}`)
+
+	// changes are reconciled in the background independently of the flush interval
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelInfo}))
+	_, err = logs.GlogSetter("9")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return getKlogLevel() == klogLevelInfo
+	}, 10*klogReconcileInterval, time.Millisecond)
+	require.False(t, Enabled(LevelDebug))
+
+	// levels beyond what klogVerbosity can detect are not mistaken for changes made outside of mlog
+	require.NoError(t, ValidateAndSetKlogLevelAndFormatGlobally(ctx, 200, FormatJSON))
+	require.Equal(t, klog.Level(math.MaxInt8), getKlogLevel())
+	warnings := bytes.Count(buf.Bytes(), []byte("klog verbosity was changed"))
+	reconcileKlogLevel()
+	require.Equal(t, warnings, bytes.Count(buf.Bytes(), []byte("klog verbosity was changed")))
+	require.True(t, Enabled(LevelAll))
+}
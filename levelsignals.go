@@ -0,0 +1,59 @@
+package mlog
+
+import (
+	"context"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// levelLadder lists the mlog levels in order of increasing verbosity.
+var levelLadder = []LogLevel{LevelWarning, LevelInfo, LevelDebug, LevelTrace, LevelAll} //nolint:gochecknoglobals
+
+func handleLevelSignals(ctx context.Context, signals <-chan os.Signal, up, down os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			switch sig {
+			case up:
+				stepLevel(sig, 1)
+			case down:
+				stepLevel(sig, -1)
+			}
+		}
+	}
+}
+
+// stepLevel moves the configured global log level by delta notches on levelLadder.
+func stepLevel(sig os.Signal, delta int) {
+	globalSpecLock.Lock()
+	defer globalSpecLock.Unlock()
+
+	// the configured level may be an arbitrary klog level so find the closest notch on the ladder
+	oldLevel := zapLevelToMlogLevel(zapcore.Level(-globalKlogLevel))
+
+	i := 0
+	for j, level := range levelLadder {
+		if level == oldLevel {
+			i = j
+			break
+		}
+	}
+
+	i += delta
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(levelLadder) {
+		i = len(levelLadder) - 1
+	}
+	newLevel := levelLadder[i]
+
+	globalSpec.Level = newLevel
+	globalKlogLevel = klogLevelForMlogLevel(newLevel)
+	updateLevelGlobally()
+
+	Always("log level changed via signal", "signal", sig.String(), "old", oldLevel, "new", newLevel)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package mlog
+
+import "context"
+
+// HandleLevelSignals is a no-op on platforms that do not support SIGUSR1 and SIGUSR2.
+func HandleLevelSignals(_ context.Context) {}
@@ -0,0 +1,99 @@
+package mlog
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestHandleLevelSignals(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelDebug, LoggerLevels: map[string]LogLevel{"leader": ""}}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	up, down, noop := testSignal("up"), testSignal("down"), testSignal("noop")
+
+	signalCtx, signalCancel := context.WithCancel(ctx)
+	signals := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handleLevelSignals(signalCtx, signals, up, down)
+	}()
+
+	send := func(sigs ...os.Signal) {
+		t.Helper()
+		for _, sig := range sigs {
+			signals <- sig
+		}
+		signals <- noop // wait for the previous signals to be fully processed
+	}
+
+	send(up)
+	require.Equal(t, klog.Level(6), getKlogLevel())
+	require.Equal(t, LogSpec{Level: LevelTrace, Format: FormatJSON, LoggerLevels: map[string]LogLevel{"leader": ""}}, CurrentSpec())
+
+	send(up, up)
+	require.Equal(t, klog.Level(108), getKlogLevel())
+	require.Equal(t, LevelAll, CurrentSpec().Level)
+
+	send(down, down, down, down, down)
+	require.Equal(t, klog.Level(0), getKlogLevel())
+	require.Equal(t, LevelWarning, CurrentSpec().Level)
+	require.False(t, Enabled(LevelInfo))
+
+	send(up)
+	require.Equal(t, klog.Level(2), getKlogLevel())
+	require.Equal(t, LogSpec{Level: LevelInfo, Format: FormatJSON, LoggerLevels: map[string]LogLevel{"leader": ""}}, CurrentSpec())
+
+	signalCancel()
+	<-done
+
+	type changeLog struct {
+		Message  string
+		Signal   string
+		Old, New LogLevel
+	}
+	var logs []changeLog
+	for _, line := range jsonLines(t, buf.Bytes()) {
+		old, _ := line["old"].(string)
+		updated, _ := line["new"].(string)
+		logs = append(logs, changeLog{Message: line["message"].(string), Signal: line["signal"].(string), Old: LogLevel(old), New: LogLevel(updated)})
+	}
+
+	require.Equal(t, []changeLog{
+		{Message: "log level changed via signal", Signal: "up", Old: LevelDebug, New: LevelTrace},
+		{Message: "log level changed via signal", Signal: "up", Old: LevelTrace, New: LevelAll},
+		{Message: "log level changed via signal", Signal: "up", Old: LevelAll, New: LevelAll},
+		{Message: "log level changed via signal", Signal: "down", Old: LevelAll, New: LevelTrace},
+		{Message: "log level changed via signal", Signal: "down", Old: LevelTrace, New: LevelDebug},
+		{Message: "log level changed via signal", Signal: "down", Old: LevelDebug, New: LevelInfo},
+		{Message: "log level changed via signal", Signal: "down", Old: LevelInfo, New: LevelWarning},
+		{Message: "log level changed via signal", Signal: "down", Old: LevelWarning, New: LevelWarning},
+		{Message: "log level changed via signal", Signal: "up", Old: LevelWarning, New: LevelInfo},
+	}, logs)
+}
+
+var _ os.Signal = testSignal("")
+
+type testSignal string
+
+func (s testSignal) String() string { return string(s) }
+func (testSignal) Signal()          {}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package mlog
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleLevelSignals steps the global log level one notch up the warning, info, debug, trace, all ladder
+// whenever the process receives a SIGUSR1 and one notch down whenever it receives a SIGUSR2.
+// This is opt-in because the default action of these signals is to terminate the process.
+// The signals are handled in a background go routine until ctx is done.
+func HandleLevelSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(signals)
+		handleLevelSignals(ctx, signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	}()
+}
@@ -0,0 +1,190 @@
+package mlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFlushInterval = time.Minute
+
+	// klogReconcileInterval is independent of the flush interval so that changes to klog's verbosity
+	// are reverted quickly, see reconcileKlogLevelLocked.
+	klogReconcileInterval = time.Second
+
+	// stderrSinkURL is used instead of zap's "stderr" path so that we can ignore the expected sync errors, see stderrSink.
+	stderrSinkURL = stderrSinkScheme + ":"
+
+	stderrSinkScheme = "monis.app-mlog-stderr"
+
+	ErrInvalidLogFlushInterval = constableError("invalid log flush interval, must not be negative")
+)
+
+// Shutdown stops the periodic flushing of logs that was started by ValidateAndSetLogLevelAndFormatGlobally
+// and then performs a final flush.  It waits for the flush to complete until ctx is done, and returns any
+// errors from syncing the log sinks.  Loggers remain usable after Shutdown, but are no longer flushed
+// in the background.
+func Shutdown(ctx context.Context) error {
+	globalSpecLock.Lock()
+	l := globalLifecycle
+	globalLifecycle = nil
+	flush := globalLoggers.Load().flush
+	globalSpecLock.Unlock()
+
+	if l != nil {
+		if err := l.stop(ctx); err != nil {
+			return err
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- flush()
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("failed to flush logs: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush logs: %w", ctx.Err())
+	}
+}
+
+// lifecycle owns the background go routine that periodically flushes the global logger.
+// there is at most one lifecycle at a time, see globalLifecycle.
+type lifecycle struct {
+	stopCh chan struct{}
+	done   chan struct{}
+
+	ownerFlushes bool // set by stop before stopCh is closed, the go routine skips its final flush
+}
+
+// startLifecycle starts periodically flushing (and logging sampling summaries) until either ctx is done or
+// the lifecycle is stopped, in both cases a best effort final flush is performed unless stop was used.
+// the loggers are also released when the lifecycle is stopped without stop, i.e. when they were replaced.
+// reconcile is optional and is called every klogReconcileInterval.
+func startLifecycle(ctx context.Context, interval time.Duration, logs *loggers, sampler *sampler, reconcile func()) *lifecycle {
+	l := &lifecycle{stopCh: make(chan struct{}), done: make(chan struct{})}
+
+	go func() {
+		defer close(l.done)
+
+		flushTicker := time.NewTicker(interval)
+		defer flushTicker.Stop()
+
+		var reconcileTick <-chan time.Time
+		if reconcile != nil {
+			reconcileTicker := time.NewTicker(klogReconcileInterval)
+			defer reconcileTicker.Stop()
+			reconcileTick = reconcileTicker.C
+		}
+
+		var summary <-chan time.Time
+		if sampler != nil {
+			summaryTicker := time.NewTicker(samplingSummaryInterval)
+			defer summaryTicker.Stop()
+			summary = summaryTicker.C
+
+			defer sampler.logSummary() // do not lose the counts when the config changes or on shutdown
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				reportFlushError(logs.flush())
+				return
+			case <-l.stopCh:
+				if !l.ownerFlushes {
+					logs.close() // the loggers were replaced but they may still have buffered logs
+				}
+				return
+			case <-flushTicker.C:
+				reportFlushError(logs.flush())
+			case <-reconcileTick:
+				reconcile()
+			case <-summary:
+				sampler.logSummary()
+			}
+		}
+	}()
+
+	return l
+}
+
+// stop stops the lifecycle's go routine and waits for it to exit until ctx is done.  the caller
+// must perform the final flush (and release the loggers if needed) so that it can report its errors.
+func (l *lifecycle) stop(ctx context.Context) error {
+	l.ownerFlushes = true // closing stopCh publishes this write to the go routine
+	close(l.stopCh)
+
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop log flushing: %w", ctx.Err())
+	}
+}
+
+// stopLifecycleLocked stops the current lifecycle without waiting for it, which is required because
+// its go routine may acquire globalSpecLock.  the caller must hold globalSpecLock.
+func stopLifecycleLocked() {
+	if globalLifecycle == nil {
+		return
+	}
+	close(globalLifecycle.stopCh)
+	globalLifecycle = nil
+}
+
+func reportFlushError(err error) {
+	if err == nil {
+		return
+	}
+	// the loggers cannot be trusted to report their own failures
+	_, _ = fmt.Fprintf(os.Stderr, "mlog: failed to flush logs: %v\n", err)
+}
+
+func reportReleaseError(err error) {
+	if err == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "mlog: failed to close log files: %v\n", err)
+}
+
+var _ zap.Sink = stderrSink{}
+
+// stderrSink is os.Stderr with Sync errors ignored when stderr does not support syncing, i.e. a terminal or a pipe.
+type stderrSink struct{}
+
+func openStderrSink(_ *url.URL) (zap.Sink, error) {
+	return stderrSink{}, nil
+}
+
+func (stderrSink) Write(p []byte) (int, error) {
+	return os.Stderr.Write(p)
+}
+
+func (stderrSink) Sync() error {
+	return syncStderr()
+}
+
+func (stderrSink) Close() error {
+	return nil // never close stderr
+}
+
+func syncStderr() error {
+	if err := os.Stderr.Sync(); err != nil &&
+		!errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) && !errors.Is(err, syscall.ENOTTY) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package mlog
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+func TestShutdown(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	w := &syncBuffer{}
+	ctx = TestZapOverrides(ctx, t, w, nil)
+
+	spec := LogSpec{FlushInterval: &metav1.Duration{Duration: time.Millisecond}}
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, spec))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+		require.NoError(t, Shutdown(context.Background()))
+	})
+
+	// logs are periodically flushed in the background
+	require.Eventually(t, func() bool {
+		return w.syncs.Load() > 1
+	}, time.Minute, time.Millisecond)
+
+	// changing the config stops the previous go routine
+	first := currentLifecycle()
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, spec))
+	second := currentLifecycle()
+	require.NotSame(t, first, second)
+	requireStopped(t, first)
+
+	// a stopped lifecycle performs a final flush and releases its loggers since they may have been replaced
+	var flushes, releases atomic.Int64
+	logs := &loggers{flush: func() error { flushes.Add(1); return nil }, release: func() error { releases.Add(1); return nil }}
+	stopped := startLifecycle(context.Background(), time.Hour, logs, nil, nil)
+	close(stopped.stopCh)
+	requireStopped(t, stopped)
+	require.Equal(t, int64(1), flushes.Load())
+	require.Equal(t, int64(1), releases.Load())
+
+	// sync errors are reported by Shutdown
+	w.fail.Store(true)
+	require.EqualError(t, Shutdown(context.Background()), "failed to flush logs: sync failed")
+	require.Nil(t, currentLifecycle())
+	requireStopped(t, second)
+
+	// background flush stops once shut down
+	syncs := w.syncs.Load()
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, syncs, w.syncs.Load())
+
+	w.fail.Store(false)
+	require.NoError(t, Shutdown(context.Background()))
+
+	// the go routine also exits once the context is done
+	cliCtx, cliCancel := context.WithCancel(ctx)
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(cliCtx, LogSpec{}))
+	third := currentLifecycle()
+	cliCancel()
+	requireStopped(t, third)
+
+	// the CLI does not flush in the background
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Format: FormatCLI}))
+	require.Nil(t, currentLifecycle())
+
+	require.ErrorIs(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{FlushInterval: &metav1.Duration{Duration: -time.Second}}), ErrInvalidLogFlushInterval)
+}
+
+func currentLifecycle() *lifecycle {
+	globalSpecLock.Lock()
+	defer globalSpecLock.Unlock()
+
+	return globalLifecycle
+}
+
+func requireStopped(t *testing.T, l *lifecycle) {
+	t.Helper()
+
+	select {
+	case <-l.done:
+	case <-time.After(time.Minute):
+		t.Fatal("lifecycle go routine did not exit")
+	}
+}
@@ -66,6 +66,7 @@ var _ Logger = mLogger{}
 var _, _, _ minLogger = mLogger{}, logr.Logger{}, Logger(nil)
 
 type mLogger struct {
+	log   *logr.Logger // optional, the global logger is used if unset, see NewWithConfig
 	mods  []func(logr.Logger) logr.Logger
 	depth int
 }
@@ -79,13 +80,12 @@ func (p mLogger) Error(msg string, err error, keysAndValues ...interface{}) {
 }
 
 func (p mLogger) warningDepth(msg string, depth int, keysAndValues ...interface{}) {
-	if p.logr().V(klogLevelWarning).Enabled() {
+	if l := p.logr().V(klogLevelWarning); l.Enabled() {
 		// klog's structured logging has no concept of a warning (i.e. no WarningS function)
-		// Thus we use info at log level zero as a proxy
-		// klog's info logs have an I prefix and its warning logs have a W prefix
-		// Since we lose the W prefix by using InfoS, just add a key to make these easier to find
-		keysAndValues = append([]interface{}{"warning", true}, keysAndValues...)
-		p.logr().V(klogLevelWarning).WithCallDepth(depth+1).Info(msg, keysAndValues...)
+		// Thus we use info at log level zero as a proxy along with a marker that our loggers
+		// use to encode the warning level, see warningKey
+		keysAndValues = append([]interface{}{warningKey, warningMarker(true)}, keysAndValues...)
+		l.WithCallDepth(depth+1).Info(msg, keysAndValues...)
 	}
 }
 
@@ -98,8 +98,8 @@ func (p mLogger) WarningErr(msg string, err error, keysAndValues ...interface{})
 }
 
 func (p mLogger) infoDepth(msg string, depth int, keysAndValues ...interface{}) {
-	if p.logr().V(klogLevelInfo).Enabled() {
-		p.logr().V(klogLevelInfo).WithCallDepth(depth+1).Info(msg, keysAndValues...)
+	if l := p.logr().V(klogLevelInfo); l.Enabled() {
+		l.WithCallDepth(depth+1).Info(msg, keysAndValues...)
 	}
 }
 
@@ -112,8 +112,8 @@ func (p mLogger) InfoErr(msg string, err error, keysAndValues ...interface{}) {
 }
 
 func (p mLogger) debugDepth(msg string, depth int, keysAndValues ...interface{}) {
-	if p.logr().V(klogLevelDebug).Enabled() {
-		p.logr().V(klogLevelDebug).WithCallDepth(depth+1).Info(msg, keysAndValues...)
+	if l := p.logr().V(klogLevelDebug); l.Enabled() {
+		l.WithCallDepth(depth+1).Info(msg, keysAndValues...)
 	}
 }
 
@@ -126,8 +126,8 @@ func (p mLogger) DebugErr(msg string, err error, keysAndValues ...interface{}) {
 }
 
 func (p mLogger) traceDepth(msg string, depth int, keysAndValues ...interface{}) {
-	if p.logr().V(klogLevelTrace).Enabled() {
-		p.logr().V(klogLevelTrace).WithCallDepth(depth+1).Info(msg, keysAndValues...)
+	if l := p.logr().V(klogLevelTrace); l.Enabled() {
+		l.WithCallDepth(depth+1).Info(msg, keysAndValues...)
 	}
 }
 
@@ -140,8 +140,8 @@ func (p mLogger) TraceErr(msg string, err error, keysAndValues ...interface{}) {
 }
 
 func (p mLogger) All(msg string, keysAndValues ...interface{}) {
-	if p.logr().V(klogLevelAll).Enabled() {
-		p.logr().V(klogLevelAll).WithCallDepth(p.depth+1).Info(msg, keysAndValues...)
+	if l := p.logr().V(klogLevelAll); l.Enabled() {
+		l.WithCallDepth(p.depth+1).Info(msg, keysAndValues...)
 	}
 }
 
@@ -185,7 +185,7 @@ func (p mLogger) withLogrMod(mod func(logr.Logger) logr.Logger) Logger {
 }
 
 func (p mLogger) logr() logr.Logger {
-	l := Logr() // grab the current global logger and its current config
+	l := p.root()
 	for _, mod := range p.mods {
 		mod := mod
 		l = mod(l) // and then update it with all modifications
@@ -193,6 +193,13 @@ func (p mLogger) logr() logr.Logger {
 	return l // this logger is guaranteed to have the latest config and all modifications
 }
 
+func (p mLogger) root() logr.Logger {
+	if p.log != nil {
+		return *p.log // this logger has its own config which never changes
+	}
+	return Logr() // grab the current global logger and its current config
+}
+
 var logger = New().withDepth(1) //nolint:gochecknoglobals
 
 func Error(msg string, err error, keysAndValues ...interface{}) {
@@ -251,6 +258,7 @@ func WithName(name string) Logger {
 
 func Fatal(err error, keysAndValues ...interface{}) {
 	logger.Error("unrecoverable error encountered", err, keysAndValues...)
-	globalFlush()
+	dumpEarlyBuffer() // do not lose the logs that explain why we are exiting
+	_ = globalLoggers.Load().flush()
 	os.Exit(1)
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,8 +22,8 @@ func TestMlog(t *testing.T) {
 			run:  testAllMlogMethods,
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"d","panda":2}
@@ -40,8 +41,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"e","hi":42,"panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","hi":42,"warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","hi":42,"warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","hi":42,"panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","hi":42,"error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"i","hi":42,"panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"ie","hi":42,"error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"d","hi":42,"panda":2}
@@ -59,8 +60,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"e","panda":false,"panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","panda":false,"warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","panda":false,"warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","panda":false,"panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","panda":false,"error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"i","panda":false,"panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"ie","panda":false,"error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"d","panda":false,"panda":2}
@@ -78,8 +79,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"e","hi":42,"not":"1h0m0s","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","hi":42,"not":"1h0m0s","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","hi":42,"not":"1h0m0s","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","hi":42,"not":"1h0m0s","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","hi":42,"not":"1h0m0s","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"i","hi":42,"not":"1h0m0s","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"ie","hi":42,"not":"1h0m0s","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"d","hi":42,"not":"1h0m0s","panda":2}
@@ -97,8 +98,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"d","panda":2}
@@ -116,8 +117,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo.gold","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo.gold","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo.gold","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo.gold","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo.gold","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo.gold","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo.gold","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","logger":"yoyo.gold","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"d","panda":2}
@@ -135,8 +136,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"testing/testing.go:<line>$testing.tRunner","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"testing/testing.go:<line>$testing.tRunner","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"testing/testing.go:<line>$testing.tRunner","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"testing/testing.go:<line>$testing.tRunner","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"testing/testing.go:<line>$testing.tRunner","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"testing/testing.go:<line>$testing.tRunner","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"testing/testing.go:<line>$testing.tRunner","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"testing/testing.go:<line>$testing.tRunner","message":"d","panda":2}
@@ -154,8 +155,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func15","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func15","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func15","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func15","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func15","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func15","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func15","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func15","message":"d","panda":2}
@@ -173,8 +174,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func8","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func8","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func8","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func8","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func8","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func8","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func8","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func8","message":"d","panda":2}
@@ -192,8 +193,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.testAllMlogMethods","message":"d","panda":2}
@@ -211,8 +212,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Error","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Warning","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.WarningErr","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Warning","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.WarningErr","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Info","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.InfoErr","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Debug","message":"d","panda":2}
@@ -230,8 +231,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"logr@v1.2.3/logr.go:<line>$logr.Logger.Error","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.warningDepth","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.warningDepth","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.warningDepth","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.warningDepth","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.infoDepth","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.infoDepth","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.debugDepth","message":"d","panda":2}
@@ -249,8 +250,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"zapr@v1.2.3/zapr.go:<line>$zapr.(*zapLogger).Error","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"logr@v1.2.3/logr.go:<line>$logr.Logger.Info","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"logr@v1.2.3/logr.go:<line>$logr.Logger.Info","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"logr@v1.2.3/logr.go:<line>$logr.Logger.Info","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"logr@v1.2.3/logr.go:<line>$logr.Logger.Info","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"logr@v1.2.3/logr.go:<line>$logr.Logger.Info","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"logr@v1.2.3/logr.go:<line>$logr.Logger.Info","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"logr@v1.2.3/logr.go:<line>$logr.Logger.Info","message":"d","panda":2}
@@ -284,8 +285,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func13.1.1","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func13.1.1","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func13.1.1","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func13.1.1","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func13.1.1","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func13.1.1","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func13.1.1","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog_test.go:<line>$mlog.TestMlog.func13.1.1","message":"d","panda":2}
@@ -320,8 +321,8 @@ func TestMlog(t *testing.T) {
 			},
 			want: `
 {"level":"error","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Error","message":"e","panda":2,"error":"some err"}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Warning","message":"w","warning":true,"panda":2}
-{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.WarningErr","message":"we","warning":true,"error":"some err","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Warning","message":"w","panda":2}
+{"level":"warning","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.WarningErr","message":"we","error":"some err","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Info","message":"i","panda":2}
 {"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.InfoErr","message":"ie","error":"some err","panda":2}
 {"level":"debug","timestamp":"2099-08-08T13:57:36.123456Z","caller":"mlog/mlog.go:<line>$mlog.mLogger.Debug","message":"d","panda":2}
@@ -346,6 +347,22 @@ func TestMlog(t *testing.T) {
 	}
 }
 
+func TestMlogSingleSnapshot(t *testing.T) {
+	var log bytes.Buffer
+	root := TestZapr(t, &log)
+
+	var calls int
+	l := mLogger{log: &root}.withLogrMod(func(l logr.Logger) logr.Logger {
+		calls++
+		return l
+	})
+
+	testAllMlogMethods(l)
+
+	require.Equal(t, 11, calls, "each log call must use a single snapshot of the logger")
+	require.Equal(t, 11, strings.Count(log.String(), "\n"))
+}
+
 func testAllMlogMethods(l Logger) {
 	testErr := fmt.Errorf("some err")
 
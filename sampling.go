@@ -0,0 +1,241 @@
+package mlog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	defaultSamplingInitial    = 100
+	defaultSamplingThereafter = 100
+	defaultSamplingTick       = time.Second
+
+	samplingSummaryInterval = time.Minute
+
+	ErrInvalidLogSampling = constableError("invalid log sampling, counts and tick must not be negative and levels must be valid log levels")
+)
+
+// SamplingSpec configures sampling of repetitive logs.  Within each tick, the first Initial entries with
+// the same level and message are logged, after which only every Thereafter-th entry is logged.
+// A summary of how many entries were dropped per message is periodically logged.
+// Sampling is not supported by the CLI format (which has no background go routine to log the summary)
+// or by the deprecated text format.
+type SamplingSpec struct {
+	// Initial defaults to 100.
+	Initial int `json:"initial,omitempty"`
+	// Thereafter defaults to 100.
+	Thereafter int `json:"thereafter,omitempty"`
+	// Tick defaults to 1s.
+	Tick metav1.Duration `json:"tick,omitempty"`
+	// Levels are the log levels that are sampled, defaults to info, debug, trace and all.
+	// Errors are never sampled.  Warning and Always logs are only sampled if the empty string is included.
+	Levels []LogLevel `json:"levels,omitempty"`
+}
+
+func (s *SamplingSpec) deepCopy() *SamplingSpec {
+	if s == nil {
+		return nil
+	}
+	out := *s
+	if s.Levels != nil {
+		out.Levels = append([]LogLevel(nil), s.Levels...)
+	}
+	return &out
+}
+
+func (s *SamplingSpec) validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if s.Initial < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("initial"), s.Initial, "must not be negative"))
+	}
+	if s.Thereafter < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("thereafter"), s.Thereafter, "must not be negative"))
+	}
+	if s.Tick.Duration < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("tick"), s.Tick.Duration.String(), "must not be negative"))
+	}
+	for i, level := range s.Levels {
+		errs = append(errs, validateLevel(fldPath.Child("levels").Index(i), level)...)
+	}
+	return errs
+}
+
+// sampler returns the sampler described by the already validated spec, or nil if sampling is disabled.
+func (s *SamplingSpec) sampler() *sampler {
+	if s == nil {
+		return nil
+	}
+
+	out := &sampler{
+		initial:    uint64(s.Initial),
+		thereafter: uint64(s.Thereafter),
+		tick:       s.Tick.Duration,
+		levels:     map[LogLevel]struct{}{},
+		counts:     map[samplingKey]uint64{},
+		dropped:    map[string]int64{},
+	}
+	if out.initial == 0 {
+		out.initial = defaultSamplingInitial
+	}
+	if out.thereafter == 0 {
+		out.thereafter = defaultSamplingThereafter
+	}
+	if out.tick == 0 {
+		out.tick = defaultSamplingTick
+	}
+
+	levels := s.Levels
+	if len(levels) == 0 {
+		levels = []LogLevel{LevelInfo, LevelDebug, LevelTrace, LevelAll}
+	}
+	for _, level := range levels {
+		out.levels[level] = struct{}{}
+	}
+
+	return out
+}
+
+type samplingKey struct {
+	level   zapcore.Level
+	message string
+}
+
+// sampler is shared by all outputs so that an entry is either written to all of them or dropped.
+type sampler struct {
+	initial, thereafter uint64
+	tick                time.Duration
+	levels              map[LogLevel]struct{}
+	log                 Logger // optional, the summary is logged via the global logger if unset
+
+	mu      sync.Mutex
+	resetAt time.Time
+	counts  map[samplingKey]uint64
+	dropped map[string]int64 // per message, reset by summary
+}
+
+func (s *sampler) sampled(level zapcore.Level) bool {
+	if level > 0 {
+		return false // errors are never sampled
+	}
+	_, ok := s.levels[zapLevelToMlogLevel(level)]
+	return ok
+}
+
+// drop records the entry and reports if it should be dropped.
+func (s *sampler) drop(ent zapcore.Entry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// use the entry's time to respect the logger's clock
+	if !ent.Time.Before(s.resetAt) {
+		s.counts = map[samplingKey]uint64{} // also bounds memory use to the messages seen within a single tick
+		s.resetAt = ent.Time.Add(s.tick)
+	}
+
+	key := samplingKey{level: ent.Level, message: ent.Message}
+	s.counts[key]++
+	n := s.counts[key]
+
+	if n <= s.initial || (n-s.initial)%s.thereafter == 0 {
+		return false
+	}
+
+	s.dropped[ent.Message]++
+	return true
+}
+
+// summary returns how many entries were dropped per message since the last call.
+func (s *sampler) summary() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.dropped) == 0 {
+		return nil
+	}
+
+	dropped := s.dropped
+	s.dropped = map[string]int64{}
+	return dropped
+}
+
+func (s *sampler) logSummary() {
+	dropped := s.summary()
+	if len(dropped) == 0 {
+		return
+	}
+
+	var total int64
+	for _, n := range dropped {
+		total += n
+	}
+
+	log := s.log
+	if log == nil {
+		log = New()
+	}
+	log.Always("dropped sampled logs", "total", total, "dropped", dropped)
+}
+
+var _ zapcore.Core = &samplingCore{}
+
+// samplingCore applies sampling before the entry is teed to each output.  only entries that are enabled
+// by the configured level (or the logger's override, or a vmodule pattern) are counted, the outputs perform
+// the actual level checks.
+type samplingCore struct {
+	core    zapcore.Core
+	level   zapcore.LevelEnabler
+	levels  *atomic.Pointer[loggerLevels]
+	vmodule *atomic.Pointer[vmodule] // optional, see LogSpec.VModule
+	sampler *sampler
+}
+
+func (s *samplingCore) Enabled(level zapcore.Level) bool {
+	return s.core.Enabled(level)
+}
+
+func (s *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplingCore{core: s.core.With(fields), level: s.level, levels: s.levels, vmodule: s.vmodule, sampler: s.sampler}
+}
+
+func (s *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !s.sampler.sampled(ent.Level) {
+		return s.core.Check(ent, ce)
+	}
+
+	if enabledFor(s.level, s.levels, ent) {
+		if s.sampler.drop(ent) {
+			return ce
+		}
+		return s.core.Check(ent, ce)
+	}
+
+	// zap only collects the caller after the entry has been checked, so defer sampling to Write, see levelCore
+	if loadVModule(s.vmodule).enabled(ent.Level) {
+		return ce.AddCore(ent, s)
+	}
+
+	return s.core.Check(ent, ce)
+}
+
+func (s *samplingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	// this is only reached for entries that may be enabled by a vmodule pattern, see Check
+	if !loadVModule(s.vmodule).enabledFor(ent) || s.sampler.drop(ent) {
+		return nil
+	}
+
+	// the wrapped core has not checked the entry yet, and now that the caller is known its checks can succeed
+	if ce := s.core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+func (s *samplingCore) Sync() error {
+	return s.core.Sync()
+}
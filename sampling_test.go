@@ -0,0 +1,194 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+func TestSampling(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	err := ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{
+		Level:        LevelDebug,
+		LoggerLevels: map[string]LogLevel{"quiet": LevelWarning},
+		Sampling:     &SamplingSpec{Initial: 2, Thereafter: 3, Tick: metav1.Duration{Duration: time.Hour}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	for i := 0; i < 10; i++ {
+		Debug("debug spam", "i", i)
+		Info("info spam", "i", i)
+		Trace("disabled spam", "i", i)
+		WithName("quiet").Info("disabled spam", "i", i)
+		Warning("warning spam", "i", i)
+		Always("always spam", "i", i)
+		Error("error spam", ErrInvalidLogLevel, "i", i)
+	}
+
+	counts := map[string][]int{}
+	for _, line := range jsonLines(t, buf.Bytes()) {
+		message := line["message"].(string)
+		counts[message] = append(counts[message], int(line["i"].(float64)))
+	}
+
+	all := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	require.Equal(t, map[string][]int{
+		"debug spam":   {0, 1, 4, 7},
+		"info spam":    {0, 1, 4, 7},
+		"warning spam": all,
+		"always spam":  all,
+		"error spam":   all,
+	}, counts)
+
+	// the summary of dropped logs is not lost when the config changes
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{}))
+	require.Eventually(t, func() bool {
+		return bytes.Contains(buf.Bytes(), []byte(`"message":"dropped sampled logs","total":12,"dropped":{"debug spam":6,"info spam":6}`))
+	}, time.Minute, 10*time.Millisecond)
+
+	for _, invalid := range []*SamplingSpec{
+		{Initial: -1},
+		{Thereafter: -1},
+		{Tick: metav1.Duration{Duration: -time.Second}},
+		{Levels: []LogLevel{"panda"}},
+	} {
+		require.ErrorIs(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Sampling: invalid}), ErrInvalidLogSampling)
+	}
+}
+
+func TestSamplingVModule(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	err := ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{
+		Level:    LevelWarning,
+		VModule:  []VModuleSpec{{Pattern: "sampling_test", Level: LevelTrace}},
+		Sampling: &SamplingSpec{Initial: 2, Thereafter: 3, Tick: metav1.Duration{Duration: time.Hour}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	// entries that are only enabled by a vmodule pattern are sampled just like all other entries
+	for i := 0; i < 10; i++ {
+		Trace("trace spam", "i", i)
+		Warning("warning spam", "i", i)
+	}
+
+	counts := map[string][]int{}
+	for _, line := range jsonLines(t, buf.Bytes()) {
+		message := line["message"].(string)
+		counts[message] = append(counts[message], int(line["i"].(float64)))
+	}
+
+	require.Equal(t, map[string][]int{
+		"trace spam":   {0, 1, 4, 7},
+		"warning spam": {0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	}, counts)
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{}))
+	require.Eventually(t, func() bool {
+		return bytes.Contains(buf.Bytes(), []byte(`"message":"dropped sampled logs","total":6,"dropped":{"trace spam":6}`))
+	}, time.Minute, 10*time.Millisecond)
+}
+
+func TestSamplingCLI(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	// the CLI never logs the summary of dropped logs so it does not drop any
+	sampling := &SamplingSpec{Initial: 1, Thereafter: 100, Tick: metav1.Duration{Duration: time.Hour}}
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Format: FormatCLI, Sampling: sampling}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	for i := 0; i < 10; i++ {
+		Always("cli spam")
+	}
+	require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("log.sampling is ignored when log.format is 'cli'")))
+	require.Equal(t, 10, bytes.Count(buf.Bytes(), []byte("cli spam")))
+
+	var instanceBuf syncBuffer
+	log, err := NewWithConfig(LogSpec{Format: FormatCLI, Sampling: sampling}, WithWriter(&instanceBuf))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		log.Warning("cli spam")
+	}
+	require.NoError(t, log.Close())
+	require.Equal(t, 1, bytes.Count(instanceBuf.Bytes(), []byte("log.sampling is ignored when log.format is 'cli'")))
+	require.Equal(t, 10, bytes.Count(instanceBuf.Bytes(), []byte("cli spam")))
+}
+
+func TestSampler(t *testing.T) {
+	s := (&SamplingSpec{Levels: []LogLevel{LevelWarning}}).sampler()
+	require.Equal(t, uint64(defaultSamplingInitial), s.initial)
+	require.Equal(t, uint64(defaultSamplingThereafter), s.thereafter)
+	require.Equal(t, defaultSamplingTick, s.tick)
+
+	require.True(t, s.sampled(zapcore.Level(0)))
+	require.False(t, s.sampled(zapcore.Level(-klogLevelInfo)))
+	require.False(t, s.sampled(zapcore.ErrorLevel))
+
+	now := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	logMany := func(msg string, n int) (dropped int) {
+		for i := 0; i < n; i++ {
+			if s.drop(zapcore.Entry{Message: msg, Time: now}) {
+				dropped++
+			}
+		}
+		return dropped
+	}
+
+	require.Equal(t, 0, logMany("a", 100))
+	require.Equal(t, 99, logMany("a", 100))
+	require.Equal(t, 0, logMany("b", 50))
+
+	// counts are reset once the tick elapses
+	now = now.Add(time.Second)
+	require.Equal(t, 0, logMany("a", 100))
+	require.Equal(t, 2, logMany("a", 2))
+
+	require.Equal(t, map[string]int64{"a": 101}, s.summary())
+	require.Nil(t, s.summary())
+}
@@ -0,0 +1,393 @@
+package mlog
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// StacktracePolicy configures which logs include a stack trace.  Besides the constants below, it may be set to
+// a log level other than warning, i.e. StacktracePolicy(LevelDebug), which includes a stack trace in all logs
+// when the log level is at least that level.  Unlike StacktraceDefault, all explicit policies apply to all formats.
+// When the logged error carries its own stack trace, i.e. it was created via github.com/pkg/errors, the stack
+// trace of where the error was created is used instead of the stack trace of the log call.
+type StacktracePolicy string
+
+const (
+	// StacktraceDefault includes a stack trace in all logs when the log level is trace or all.
+	// Only the json format includes stack traces, they are too noisy for the other formats.
+	StacktraceDefault StacktracePolicy = ""
+	// StacktraceNever never includes a stack trace.
+	StacktraceNever StacktracePolicy = "never"
+	// StacktraceErrors includes a stack trace in all error logs regardless of the log level, and never in other logs.
+	StacktraceErrors StacktracePolicy = "errors"
+
+	ErrInvalidLogStacktrace  = constableError("invalid log stacktrace, valid choices are the empty string, never, errors, info, debug, trace and all")
+	ErrInvalidLogStackFilter = constableError("invalid log stack filter, modules must not be empty and max depth must not be negative")
+)
+
+//nolint:gochecknoglobals
+var validStacktracePolicies = []string{
+	string(StacktraceDefault), string(StacktraceNever), string(StacktraceErrors),
+	string(LevelInfo), string(LevelDebug), string(LevelTrace), string(LevelAll),
+}
+
+func (p StacktracePolicy) validate(fldPath *field.Path) field.ErrorList {
+	if !supported(validStacktracePolicies, string(p)) {
+		return field.ErrorList{field.NotSupported(fldPath, string(p), validStacktracePolicies)}
+	}
+	return nil
+}
+
+// enabler returns when logs include a stack trace, or nil if they never do.  atLeast returns
+// a zapcore.LevelEnabler that reports if the configured log level is at least the given level.
+func (p StacktracePolicy) enabler(atLeast func(LogLevel) zapcore.LevelEnabler) zapcore.LevelEnabler {
+	switch p {
+	case StacktraceNever:
+		return nil
+	case StacktraceErrors:
+		return zapcore.ErrorLevel
+	case StacktraceDefault:
+		return atLeast(LevelTrace)
+	default:
+		return atLeast(LogLevel(p))
+	}
+}
+
+// allFormats reports if the policy applies to formats other than json.
+func (p StacktracePolicy) allFormats() bool {
+	return p != StacktraceDefault && p != StacktraceNever
+}
+
+// originStack returns the stack trace of where the deepest error in the chain was created, if any error in the
+// chain carries its own stack trace, i.e. errors created via github.com/pkg/errors.  it is formatted like zap's.
+func originStack(err error) string {
+	var pcs []uintptr
+	for i := 0; err != nil && i < maxErrorChain; err, i = errors.Unwrap(err), i+1 {
+		if errPCs := stackTrace(err); len(errPCs) > 0 {
+			pcs = errPCs // keep going since wrapped errors are closer to the origin
+		}
+	}
+	if len(pcs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// stackTrace returns the program counters from the error's StackTrace method.  reflection is used because the
+// method returns a package specific type, i.e. errors.StackTrace from github.com/pkg/errors which is a []Frame
+// where each Frame is a uintptr.
+func stackTrace(err error) []uintptr {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil // calling the method would panic
+	}
+	m := v.MethodByName("StackTrace")
+	if !m.IsValid() {
+		return nil
+	}
+	t := m.Type()
+	if t.NumIn() != 0 || t.NumOut() != 1 || t.Out(0).Kind() != reflect.Slice || t.Out(0).Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	frames := m.Call(nil)[0]
+	pcs := make([]uintptr, 0, frames.Len())
+	for i := 0; i < frames.Len(); i++ {
+		pcs = append(pcs, uintptr(frames.Index(i).Uint()))
+	}
+	return pcs
+}
+
+// originStackFromFields returns the origin stack of the first error field that carries one.
+func originStackFromFields(fields []zapcore.Field) string {
+	for _, f := range fields {
+		if f.Type != zapcore.ErrorType {
+			continue
+		}
+		if err, ok := f.Interface.(error); ok {
+			if stack := originStack(err); len(stack) > 0 {
+				return stack
+			}
+		}
+	}
+	return ""
+}
+
+// stackCoreOption wraps the core in a stackCore.  it must be applied after errorCoreOption so that it
+// sees the original error fields, and before warningCoreOption so that its Write method is called.
+func stackCoreOption(filter *StackFilterSpec) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &stackCore{core: core, filter: filter}
+	})
+}
+
+var _ zapcore.Core = &stackCore{}
+
+// stackCore replaces the stack trace of the logging call site with the origin stack of the logged error,
+// and then filters it if configured.  entries that do not have a stack trace (as decided by the
+// StacktracePolicy) are not changed.
+type stackCore struct {
+	core   zapcore.Core
+	filter *StackFilterSpec // optional, see LogSpec.StackFilter
+	origin string           // from fields added via With
+}
+
+func (s *stackCore) Enabled(level zapcore.Level) bool {
+	return s.core.Enabled(level)
+}
+
+func (s *stackCore) With(fields []zapcore.Field) zapcore.Core {
+	origin := s.origin
+	if len(origin) == 0 {
+		origin = originStackFromFields(fields)
+	}
+	return &stackCore{core: s.core.With(fields), filter: s.filter, origin: origin}
+}
+
+func (s *stackCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if s.core.Enabled(ent.Level) {
+		return ce.AddCore(ent, s)
+	}
+	return ce
+}
+
+func (s *stackCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if len(ent.Stack) > 0 {
+		if origin := originStackFromFields(fields); len(origin) > 0 {
+			ent.Stack = origin
+		} else if len(s.origin) > 0 {
+			ent.Stack = s.origin
+		}
+		if s.filter != nil {
+			ent.Stack = s.filter.filter(ent.Stack)
+		}
+	}
+	return s.core.Write(ent, fields)
+}
+
+func (s *stackCore) Sync() error {
+	return s.core.Sync()
+}
+
+// newStackTextLogger returns a logger that adds a stacktrace key to the logs that include a stack trace
+// per the policy, since the text format has no native support for stack traces.
+func newStackTextLogger(log logr.Logger, policy StacktracePolicy, filter *StackFilterSpec) logr.Logger {
+	if !policy.allFormats() {
+		return log
+	}
+	sink := log.GetSink()
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(1) // account for the extra frame from stackSink
+	}
+	return logr.New(&stackSink{sink: sink, policy: policy, filter: filter})
+}
+
+const stacktraceKey = "stacktrace"
+
+var _ logr.CallDepthLogSink = &stackSink{}
+
+type stackSink struct {
+	sink      logr.LogSink
+	policy    StacktracePolicy
+	filter    *StackFilterSpec // optional, see LogSpec.StackFilter
+	callDepth int              // the frames between logr.Logger and the caller whose stack is taken
+}
+
+func (s *stackSink) Init(logr.RuntimeInfo) {
+	// the wrapped sink was already initialized by its logger and is adjusted for our call depth by newStackTextLogger
+}
+
+func (s *stackSink) Enabled(level int) bool {
+	return s.sink.Enabled(level)
+}
+
+func (s *stackSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	if s.policy != StacktraceErrors && Enabled(LogLevel(s.policy)) {
+		keysAndValues = s.withStack(nil, keysAndValues)
+	}
+	s.sink.Info(level, msg, keysAndValues...)
+}
+
+func (s *stackSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	if s.policy == StacktraceErrors || Enabled(LogLevel(s.policy)) {
+		keysAndValues = s.withStack(err, keysAndValues)
+	}
+	s.sink.Error(err, msg, keysAndValues...)
+}
+
+// withStack returns a new slice with the stack trace added.  it must only be called by Info and Error.
+func (s *stackSink) withStack(err error, keysAndValues []interface{}) []interface{} {
+	stack := originStack(err)
+	for i := 1; len(stack) == 0 && i < len(keysAndValues); i += 2 {
+		if kvErr, ok := keysAndValues[i].(error); ok {
+			stack = originStack(kvErr)
+		}
+	}
+	if len(stack) == 0 {
+		// skip withStack, Info or Error and logr.Logger's method to get to the caller
+		stack = zap.StackSkip("", 3+s.callDepth).String
+	}
+	if s.filter != nil {
+		stack = s.filter.filter(stack)
+	}
+	return append(keysAndValues[:len(keysAndValues):len(keysAndValues)], stacktraceKey, stack)
+}
+
+func (s *stackSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &stackSink{sink: s.sink.WithValues(keysAndValues...), policy: s.policy, filter: s.filter, callDepth: s.callDepth}
+}
+
+func (s *stackSink) WithName(name string) logr.LogSink {
+	return &stackSink{sink: s.sink.WithName(name), policy: s.policy, filter: s.filter, callDepth: s.callDepth}
+}
+
+func (s *stackSink) WithCallDepth(depth int) logr.LogSink {
+	sink := s.sink
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(depth)
+	}
+	return &stackSink{sink: sink, policy: s.policy, filter: s.filter, callDepth: s.callDepth + depth}
+}
+
+// StackFilterSpec configures how stack traces are rendered.  When set, frames from logging libraries (including
+// mlog itself) and the Go runtime are dropped, and each remaining frame is rendered on its own line in the same
+// file:line$function form that is used for the caller.
+type StackFilterSpec struct {
+	// Modules are package path prefixes, i.e. monis.app or k8s.io/client-go.  When set, consecutive frames
+	// from packages outside of these prefixes are collapsed into a single line.
+	Modules []string `json:"modules,omitempty"`
+	// MaxDepth caps the number of frames in a stack trace (not counting dropped frames), the remaining frames
+	// are collapsed into a single line.  Zero means that there is no limit.
+	MaxDepth int `json:"maxDepth,omitempty"`
+}
+
+func (s *StackFilterSpec) deepCopy() *StackFilterSpec {
+	if s == nil {
+		return nil
+	}
+	out := *s
+	if s.Modules != nil {
+		out.Modules = append([]string(nil), s.Modules...)
+	}
+	return &out
+}
+
+func (s *StackFilterSpec) validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, module := range s.Modules {
+		if len(module) == 0 {
+			errs = append(errs, field.Required(fldPath.Child("modules").Index(i), ""))
+		}
+	}
+	if s.MaxDepth < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxDepth"), s.MaxDepth, "must not be negative"))
+	}
+	return errs
+}
+
+//nolint:gochecknoglobals
+var (
+	mlogPackage = reflect.TypeOf(mLogger{}).PkgPath()
+
+	// loggingPackages are dropped from filtered stack traces along with their sub packages.
+	// tests override it to keep their own frames, which belong to mlog.
+	loggingPackages = []string{"runtime", "go.uber.org/zap", "github.com/go-logr", "k8s.io/klog", mlogPackage}
+)
+
+// filter returns the stack trace, which must be formatted like zap's, with the frames filtered per the spec.
+// the stack trace is returned as is if it cannot be parsed.
+func (s *StackFilterSpec) filter(stack string) string {
+	frames, ok := parseStack(stack)
+	if !ok {
+		return stack
+	}
+
+	var lines []string
+	depth, collapsed := 0, 0
+	collapse := func() {
+		if collapsed > 0 {
+			lines = append(lines, collapsedFrames(collapsed))
+			collapsed = 0
+		}
+	}
+	for _, frame := range frames {
+		pkg := packagePath(frame.Function)
+		if hasPathPrefix(pkg, loggingPackages) {
+			continue
+		}
+		depth++
+		// frames beyond the max depth are collapsed along with any preceding frames outside of the modules
+		if (s.MaxDepth > 0 && depth > s.MaxDepth) || (len(s.Modules) > 0 && !hasPathPrefix(pkg, s.Modules)) {
+			collapsed++
+			continue
+		}
+		collapse()
+		lines = append(lines, frame.String()+funcEncoder(frame))
+	}
+	collapse()
+
+	return strings.Join(lines, "\n")
+}
+
+// parseStack parses a stack trace that is formatted like zap's, i.e. a function line followed by a tab indented file:line line.
+func parseStack(stack string) ([]zapcore.EntryCaller, bool) {
+	lines := strings.Split(stack, "\n")
+	if len(lines)%2 != 0 {
+		return nil, false
+	}
+	frames := make([]zapcore.EntryCaller, 0, len(lines)/2)
+	for i := 0; i < len(lines); i += 2 {
+		location := strings.TrimPrefix(lines[i+1], "\t")
+		colon := strings.LastIndexByte(location, ':')
+		if len(location) == len(lines[i+1]) || colon == -1 {
+			return nil, false
+		}
+		line, err := strconv.Atoi(location[colon+1:])
+		if err != nil {
+			return nil, false
+		}
+		frames = append(frames, zapcore.EntryCaller{Defined: true, Function: lines[i], File: location[:colon], Line: line})
+	}
+	return frames, true
+}
+
+func hasPathPrefix(pkg string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if pkg == prefix || strings.HasPrefix(pkg, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func collapsedFrames(n int) string {
+	if n == 1 {
+		return "... 1 frame"
+	}
+	return "... " + strconv.Itoa(n) + " frames"
+}
@@ -0,0 +1,285 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStacktracePolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       LogSpec
+		wantStacks []bool // for info, warning and error
+	}{
+		{
+			name:       "default below trace",
+			spec:       LogSpec{Level: LevelDebug},
+			wantStacks: []bool{false, false, false},
+		},
+		{
+			name:       "default at trace",
+			spec:       LogSpec{Level: LevelTrace},
+			wantStacks: []bool{true, true, true},
+		},
+		{
+			name:       "never",
+			spec:       LogSpec{Level: LevelAll, Stacktrace: StacktraceNever},
+			wantStacks: []bool{false, false, false},
+		},
+		{
+			name:       "errors",
+			spec:       LogSpec{Stacktrace: StacktraceErrors},
+			wantStacks: []bool{false, false, true},
+		},
+		{
+			name:       "threshold reached",
+			spec:       LogSpec{Level: LevelDebug, Stacktrace: StacktracePolicy(LevelDebug)},
+			wantStacks: []bool{true, true, true},
+		},
+		{
+			name:       "threshold not reached",
+			spec:       LogSpec{Level: LevelInfo, Stacktrace: StacktracePolicy(LevelDebug)},
+			wantStacks: []bool{false, false, false},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf syncBuffer
+			log, err := NewWithConfig(tt.spec, WithWriter(&buf))
+			require.NoError(t, err)
+
+			log.Always("info")
+			log.Warning("warning")
+			log.Error("error", errors.New("oops"))
+			require.NoError(t, log.Close())
+
+			lines := jsonLines(t, buf.Bytes())
+			require.Len(t, lines, 3)
+			for i, wantStack := range tt.wantStacks {
+				stack, ok := lines[i]["stacktrace"].(string)
+				require.Equal(t, wantStack, ok, lines[i])
+				if wantStack {
+					require.True(t, strings.HasPrefix(stack, "monis.app/mlog.TestStacktracePolicy.func1\n"), stack)
+				}
+			}
+		})
+	}
+}
+
+func TestStacktracePolicyCLI(t *testing.T) {
+	for _, policy := range []StacktracePolicy{StacktraceDefault, StacktraceErrors} {
+		var buf syncBuffer
+		log, err := NewWithConfig(LogSpec{Level: LevelTrace, Format: FormatCLI, Stacktrace: policy}, WithWriter(&buf))
+		require.NoError(t, err)
+
+		log.Error("error", errors.New("oops"))
+		require.NoError(t, log.Close())
+
+		out := string(buf.Bytes())
+		if policy == StacktraceDefault {
+			require.NotContains(t, out, "TestStacktracePolicyCLI", "stack traces are opt-in for the cli format")
+			continue
+		}
+		require.Contains(t, out, "\nmonis.app/mlog.TestStacktracePolicyCLI\n\t", "explicit policies apply to all formats")
+	}
+}
+
+func TestStacktraceOrigin(t *testing.T) {
+	originErr := fmt.Errorf("failed to do the thing: %w", newTestStackError("oops"))
+
+	var buf syncBuffer
+	log, err := NewWithConfig(LogSpec{Stacktrace: StacktraceErrors}, WithWriter(&buf))
+	require.NoError(t, err)
+
+	log.Error("from the error", originErr)
+	log.WithValues("cause", originErr).Error("from the values", errors.New("no stack"))
+	log.Error("from the call", errors.New("no stack"))
+	log.Always("not an error", "error", originErr)
+	require.NoError(t, log.Close())
+
+	lines := jsonLines(t, buf.Bytes())
+	require.Len(t, lines, 4)
+	require.True(t, strings.HasPrefix(lines[0]["stacktrace"].(string), "monis.app/mlog.newTestStackError\n"), lines[0])
+	require.True(t, strings.HasPrefix(lines[1]["stacktrace"].(string), "monis.app/mlog.newTestStackError\n"), lines[1])
+	require.True(t, strings.HasPrefix(lines[2]["stacktrace"].(string), "monis.app/mlog.TestStacktraceOrigin\n"), lines[2])
+	require.NotContains(t, lines[3], "stacktrace", "the origin stack is only used when the policy includes a stack")
+}
+
+func TestStacktraceText(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := TestZapOverrides(context.Background(), t, &buf, nil)
+	text, _, err := newTextLogr(ctx, 0, logOutput{encoding: "text", stacktrace: StacktraceErrors})
+	require.NoError(t, err)
+
+	l := mLogger{log: &text.log}
+	l.Error("from the call", errors.New("no stack"))
+	l.Error("from the error", newTestStackError("oops"))
+	l.Warning("not an error")
+
+	out := buf.String()
+	parts := strings.Split(out, "\nE")
+	require.Len(t, parts, 2, out)
+
+	require.True(t, strings.HasPrefix(parts[0], "E"), out)
+	require.Contains(t, parts[0], "stacktrace_test.go:", "the caller must account for the extra frames")
+	require.Contains(t, parts[0], "stacktrace=<\n\tmonis.app/mlog.TestStacktraceText\n\t", "the stack must start at the caller")
+
+	require.Contains(t, parts[1], "stacktrace=<\n\tmonis.app/mlog.newTestStackError\n\t")
+
+	warning := out[strings.LastIndex(out, "\nW")+1:]
+	require.NotContains(t, warning, "stacktrace=")
+}
+
+type testFrame uintptr
+
+type testStackError struct {
+	msg    string
+	frames []testFrame
+}
+
+func newTestStackError(msg string) error {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(1, pcs) // skip runtime.Callers
+	frames := make([]testFrame, 0, n)
+	for _, pc := range pcs[:n] {
+		frames = append(frames, testFrame(pc))
+	}
+	return &testStackError{msg: msg, frames: frames}
+}
+
+func (e *testStackError) Error() string {
+	return e.msg
+}
+
+func (e *testStackError) StackTrace() []testFrame {
+	return e.frames
+}
+
+func TestStackFilter(t *testing.T) {
+	stack := strings.Join([]string{
+		"monis.app/mlog.(*mLogger).Error",
+		"\t/src/mlog/mlog.go:90",
+		"github.com/go-logr/zapr.(*zapLogger).Error",
+		"\t/go/pkg/mod/github.com/go-logr/zapr@v1.2.3/zapr.go:200",
+		"example.com/app/pkg/controller.(*Controller).sync",
+		"\t/src/app/pkg/controller/controller.go:42",
+		"k8s.io/client-go/util/workqueue.(*Type).Get",
+		"\t/go/pkg/mod/k8s.io/client-go/util/workqueue/queue.go:10",
+		"k8s.io/apimachinery/pkg/util/wait.JitterUntil",
+		"\t/go/pkg/mod/k8s.io/apimachinery/pkg/util/wait/wait.go:20",
+		"example.com/app/cmd.main",
+		"\t/src/app/cmd/main.go:7",
+		"runtime.main",
+		"\t/usr/local/go/src/runtime/proc.go:250",
+	}, "\n")
+
+	tests := []struct {
+		name   string
+		filter StackFilterSpec
+		stack  string
+		want   []string
+	}{
+		{
+			name: "drops logging frames",
+			want: []string{
+				"/src/app/pkg/controller/controller.go:42$controller.(*Controller).sync",
+				"/go/pkg/mod/k8s.io/client-go/util/workqueue/queue.go:10$workqueue.(*Type).Get",
+				"/go/pkg/mod/k8s.io/apimachinery/pkg/util/wait/wait.go:20$wait.JitterUntil",
+				"/src/app/cmd/main.go:7$cmd.main",
+			},
+		},
+		{
+			name:   "collapses frames outside of modules",
+			filter: StackFilterSpec{Modules: []string{"example.com/app/"}},
+			want: []string{
+				"/src/app/pkg/controller/controller.go:42$controller.(*Controller).sync",
+				"... 2 frames",
+				"/src/app/cmd/main.go:7$cmd.main",
+			},
+		},
+		{
+			name:   "caps the depth",
+			filter: StackFilterSpec{Modules: []string{"example.com/app/pkg", "k8s.io/client-go"}, MaxDepth: 2},
+			want: []string{
+				"/src/app/pkg/controller/controller.go:42$controller.(*Controller).sync",
+				"/go/pkg/mod/k8s.io/client-go/util/workqueue/queue.go:10$workqueue.(*Type).Get",
+				"... 2 frames",
+			},
+		},
+		{
+			name:   "caps frames rather than lines",
+			filter: StackFilterSpec{Modules: []string{"example.com/app/"}, MaxDepth: 2},
+			want: []string{
+				"/src/app/pkg/controller/controller.go:42$controller.(*Controller).sync",
+				"... 3 frames",
+			},
+		},
+		{
+			name:  "unparsable",
+			stack: "not\na\nstack",
+			want:  []string{"not", "a", "stack"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.stack
+			if len(in) == 0 {
+				in = stack
+			}
+			require.Equal(t, strings.Join(tt.want, "\n"), tt.filter.filter(in))
+		})
+	}
+}
+
+func TestStackFilterOutputs(t *testing.T) {
+	keepTestFrames(t)
+	filter := &StackFilterSpec{Modules: []string{mlogPackage}}
+
+	var buf syncBuffer
+	log, err := NewWithConfig(LogSpec{Stacktrace: StacktraceErrors, StackFilter: filter}, WithWriter(&buf))
+	require.NoError(t, err)
+
+	log.Error("from the call", errors.New("no stack"))
+	log.Error("from the error", newTestStackError("oops"))
+	require.NoError(t, log.Close())
+
+	lines := jsonLines(t, buf.Bytes())
+	require.Len(t, lines, 2)
+	require.Regexp(t, `^.*/stacktrace_test\.go:\d+\$mlog\.TestStackFilterOutputs\n\.\.\. 1 frame$`, lines[0]["stacktrace"])
+	require.Regexp(t, `^.*/stacktrace_test\.go:\d+\$mlog\.newTestStackError\n.*/stacktrace_test\.go:\d+\$mlog\.TestStackFilterOutputs\n\.\.\. 1 frame$`, lines[1]["stacktrace"])
+
+	var textBuf bytes.Buffer
+	ctx := TestZapOverrides(context.Background(), t, &textBuf, nil)
+	text, _, err := newTextLogr(ctx, 0, logOutput{encoding: "text", stacktrace: StacktraceErrors, stackFilter: filter})
+	require.NoError(t, err)
+
+	l := mLogger{log: &text.log}
+	l.Error("from the call", errors.New("no stack"))
+	require.Regexp(t, `stacktrace=<\n\t.*/stacktrace_test\.go:\d+\$mlog\.TestStackFilterOutputs\n\t\.\.\. 1 frame\n >\n$`, textBuf.String())
+}
+
+// keepTestFrames stops dropping mlog's frames from filtered stack traces until the test ends, since the
+// frames of the test itself belong to mlog.
+func keepTestFrames(t *testing.T) {
+	t.Helper()
+
+	original := loggingPackages
+	loggingPackages = nil
+	for _, pkg := range original {
+		if pkg != mlogPackage {
+			loggingPackages = append(loggingPackages, pkg)
+		}
+	}
+	t.Cleanup(func() {
+		loggingPackages = original
+	})
+}
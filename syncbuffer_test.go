@@ -0,0 +1,37 @@
+package mlog
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// syncBuffer is a bytes.Buffer that is safe to read while logs are being written to it from other go routines.
+// it counts calls to Sync, which fails when requested.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+
+	syncs atomic.Int64
+	fail  atomic.Bool
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) Bytes() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte(nil), s.buf.Bytes()...)
+}
+
+func (s *syncBuffer) Sync() error {
+	s.syncs.Add(1)
+	if s.fail.Load() {
+		return constableError("sync failed")
+	}
+	return nil
+}
@@ -69,10 +69,10 @@ func TestZapr(t *testing.T, w io.Writer) logr.Logger {
 	)
 
 	// there is no buffering so we can ignore flush
-	zl, _, err := newLogr(ctx, "json", 0)
+	l, err := newLogr(ctx, 0, nil, logOutput{encoding: "json"})
 	require.NoError(t, err)
 
-	return zl
+	return l.log
 }
 
 var _ zapcore.Clock = &clockAdapter{}
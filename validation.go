@@ -0,0 +1,146 @@
+package mlog
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+//nolint:gochecknoglobals
+var (
+	validLogLevels     = []string{string(LevelWarning), string(LevelInfo), string(LevelDebug), string(LevelTrace), string(LevelAll)}
+	validLogFormats    = []string{"", string(FormatJSON), string(FormatText), string(FormatCLI)}
+	validOutputFormats = []string{"", string(FormatJSON), string(FormatCLI)}
+)
+
+// Validate returns all of the problems with the LogSpec, with field paths rooted at fldPath (which may be nil).
+// This is meant to be used when a LogSpec is embedded in a larger Kubernetes style config.  It performs the
+// same validation as ValidateAndSetLogLevelAndFormatGlobally, whose error can be matched via errors.Is against
+// ErrInvalidLogLevel, ErrInvalidLogFormat, ErrInvalidLogVModule, ErrInvalidLogFile, ErrInvalidLogOutputs,
+// ErrInvalidLogSampling, ErrInvalidLogStacktrace, ErrInvalidLogStackFilter and ErrInvalidLogFlushInterval.
+func (s LogSpec) Validate(fldPath *field.Path) field.ErrorList {
+	return s.validate(fldPath).errs
+}
+
+func (s LogSpec) validate(fldPath *field.Path) *validationError {
+	v := &validationError{}
+
+	v.add(ErrInvalidLogLevel, validateLevel(fldPath.Child("level"), s.Level)...)
+	if s.KlogLevel != nil {
+		v.add(ErrInvalidLogLevel, validateLevel(fldPath.Child("klogLevel"), *s.KlogLevel)...)
+	}
+
+	if !supported(validLogFormats, string(s.Format)) {
+		v.add(ErrInvalidLogFormat, field.NotSupported(fldPath.Child("format"), string(s.Format), validLogFormats))
+	}
+
+	prefixes := make([]string, 0, len(s.LoggerLevels))
+	for prefix := range s.LoggerLevels {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes) // stable error order
+	for _, prefix := range prefixes {
+		v.add(ErrInvalidLogLevel, validateLevel(fldPath.Child("loggerLevels").Key(prefix), s.LoggerLevels[prefix])...)
+	}
+
+	for i, vmodule := range s.VModule {
+		vmodulePath := fldPath.Child("vmodule").Index(i)
+		v.add(ErrInvalidLogVModule, vmodule.validate(vmodulePath)...)
+		v.add(ErrInvalidLogLevel, validateLevel(vmodulePath.Child("level"), vmodule.Level)...)
+	}
+
+	if s.File != nil {
+		v.add(ErrInvalidLogFile, s.File.validate(fldPath.Child("file"))...)
+	}
+
+	if len(s.Outputs) > 0 {
+		if s.Format == FormatText {
+			v.add(ErrInvalidLogOutputs, field.Forbidden(fldPath.Child("format"), "the text format is not supported when outputs are set"))
+		}
+		if s.File != nil {
+			v.add(ErrInvalidLogOutputs, field.Forbidden(fldPath.Child("file"), "must be unset when outputs are set"))
+		}
+	}
+	if len(s.VModule) > 0 && s.Format == FormatText {
+		v.add(ErrInvalidLogVModule, field.Forbidden(fldPath.Child("vmodule"), "the text format is not supported when vmodule is set"))
+	}
+	for i, output := range s.Outputs {
+		outputPath := fldPath.Child("outputs").Index(i)
+		if !supported(validOutputFormats, string(output.Format)) {
+			v.add(ErrInvalidLogOutputs, field.NotSupported(outputPath.Child("format"), string(output.Format), validOutputFormats))
+		}
+		if output.Level != nil {
+			v.add(ErrInvalidLogLevel, validateLevel(outputPath.Child("level"), *output.Level)...)
+		}
+		if output.File != nil {
+			v.add(ErrInvalidLogFile, output.File.validate(outputPath.Child("file"))...)
+		}
+	}
+
+	if s.Sampling != nil {
+		v.add(ErrInvalidLogSampling, s.Sampling.validate(fldPath.Child("sampling"))...)
+	}
+
+	v.add(ErrInvalidLogStacktrace, s.Stacktrace.validate(fldPath.Child("stacktrace"))...)
+	if s.StackFilter != nil {
+		v.add(ErrInvalidLogStackFilter, s.StackFilter.validate(fldPath.Child("stackFilter"))...)
+	}
+
+	if s.FlushInterval != nil && s.FlushInterval.Duration < 0 {
+		v.add(ErrInvalidLogFlushInterval, field.Invalid(fldPath.Child("flushInterval"), s.FlushInterval.Duration.String(), "must not be negative"))
+	}
+
+	return v
+}
+
+func validateLevel(fldPath *field.Path, level LogLevel) field.ErrorList {
+	if klogLevelForMlogLevel(level) < 0 {
+		return field.ErrorList{field.NotSupported(fldPath, string(level), validLogLevels)}
+	}
+	return nil
+}
+
+func supported(valid []string, value string) bool {
+	for _, v := range valid {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var _ error = &validationError{}
+
+// validationError holds the problems with a LogSpec along with the sentinel error that each problem corresponds to.
+type validationError struct {
+	errs      field.ErrorList
+	sentinels []error
+}
+
+func (v *validationError) add(sentinel error, errs ...*field.Error) {
+	for _, err := range errs {
+		v.errs = append(v.errs, err)
+		v.sentinels = append(v.sentinels, sentinel)
+	}
+}
+
+// err returns nil if there were no problems, which avoids returning a typed nil.
+func (v *validationError) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v *validationError) Error() string {
+	return v.errs.ToAggregate().Error()
+}
+
+func (v *validationError) Is(target error) bool {
+	for _, sentinel := range v.sentinels {
+		if sentinel == target {
+			return true
+		}
+	}
+	return false
+}
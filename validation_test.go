@@ -0,0 +1,80 @@
+package mlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidate(t *testing.T) {
+	require.Empty(t, LogSpec{}.Validate(nil))
+
+	maxSize := resource.MustParse("10Mi")
+	require.Empty(t, LogSpec{
+		Level:        LevelDebug,
+		LoggerLevels: map[string]LogLevel{"webhook": LevelTrace},
+		Outputs: []OutputSpec{
+			{Format: FormatCLI, Level: levelPtr(LevelInfo)},
+			{File: &FileSpec{Path: "/var/log/app.log", MaxSize: &maxSize}},
+		},
+		Sampling:    &SamplingSpec{Levels: []LogLevel{LevelWarning}},
+		Stacktrace:  StacktraceErrors,
+		StackFilter: &StackFilterSpec{Modules: []string{"monis.app"}, MaxDepth: 10},
+	}.Validate(field.NewPath("log")))
+
+	negative := resource.MustParse("-1")
+	spec := LogSpec{
+		Level:        "panda",
+		Format:       FormatText,
+		LoggerLevels: map[string]LogLevel{"b": "bear", "a": LevelInfo},
+		File:         &FileSpec{MaxSize: &negative},
+		Outputs: []OutputSpec{
+			{Format: FormatText, Level: levelPtr("4")},
+		},
+		Sampling:    &SamplingSpec{Initial: -1, Levels: []LogLevel{"error"}},
+		Stacktrace:  "sometimes",
+		StackFilter: &StackFilterSpec{Modules: []string{""}, MaxDepth: -1},
+	}
+
+	var messages []string
+	for _, err := range spec.Validate(field.NewPath("spec", "log")) {
+		messages = append(messages, err.Error())
+	}
+	require.Equal(t, []string{
+		`spec.log.level: Unsupported value: "panda": supported values: "", "info", "debug", "trace", "all"`,
+		`spec.log.loggerLevels[b]: Unsupported value: "bear": supported values: "", "info", "debug", "trace", "all"`,
+		`spec.log.file.path: Required value`,
+		`spec.log.file.maxSize: Invalid value: "-1": must not be negative`,
+		`spec.log.format: Forbidden: the text format is not supported when outputs are set`,
+		`spec.log.file: Forbidden: must be unset when outputs are set`,
+		`spec.log.outputs[0].format: Unsupported value: "text": supported values: "", "json", "cli"`,
+		`spec.log.outputs[0].level: Unsupported value: "4": supported values: "", "info", "debug", "trace", "all"`,
+		`spec.log.sampling.initial: Invalid value: -1: must not be negative`,
+		`spec.log.sampling.levels[0]: Unsupported value: "error": supported values: "", "info", "debug", "trace", "all"`,
+		`spec.log.stacktrace: Unsupported value: "sometimes": supported values: "", "never", "errors", "info", "debug", "trace", "all"`,
+		`spec.log.stackFilter.modules[0]: Required value`,
+		`spec.log.stackFilter.maxDepth: Invalid value: -1: must not be negative`,
+	}, messages)
+
+	err := ValidateAndSetLogLevelAndFormatGlobally(context.Background(), spec)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `level: Unsupported value: "panda"`)
+	for _, sentinel := range []error{ErrInvalidLogLevel, ErrInvalidLogFile, ErrInvalidLogOutputs, ErrInvalidLogSampling, ErrInvalidLogStacktrace, ErrInvalidLogStackFilter} {
+		require.True(t, errors.Is(err, sentinel), "expected %v to match %v", err, sentinel)
+	}
+	require.False(t, errors.Is(err, ErrInvalidLogFormat))
+
+	err = ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{Format: "xml"})
+	require.EqualError(t, err, `format: Unsupported value: "xml": supported values: "", "json", "text", "cli"`)
+	require.True(t, errors.Is(err, ErrInvalidLogFormat))
+	require.False(t, errors.Is(err, ErrInvalidLogLevel))
+
+	err = ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{StackFilter: &StackFilterSpec{MaxDepth: -1}})
+	require.True(t, errors.Is(err, ErrInvalidLogStackFilter))
+	require.False(t, errors.Is(err, ErrInvalidLogStacktrace))
+}
@@ -0,0 +1,146 @@
+package mlog
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+const ErrInvalidLogVModule = constableError("invalid log vmodule, patterns must be valid globs and the text format is not supported")
+
+// VModuleSpec overrides the log level for the call sites that match Pattern.
+type VModuleSpec struct {
+	// Pattern is a glob, see path.Match.  A pattern without a slash is matched against the base name of the
+	// caller's file without the .go extension, i.e. leader* matches leaderelection.go, just like klog's -vmodule.
+	// A pattern with a slash is matched against the caller's package path, i.e. k8s.io/client-go/tools/*.
+	Pattern string `json:"pattern"`
+	// Level is the log level for the matching call sites.
+	Level LogLevel `json:"level"`
+}
+
+func (s VModuleSpec) validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch _, err := path.Match(s.Pattern, ""); {
+	case len(s.Pattern) == 0:
+		errs = append(errs, field.Required(fldPath.Child("pattern"), ""))
+	case err != nil:
+		errs = append(errs, field.Invalid(fldPath.Child("pattern"), s.Pattern, err.Error()))
+	}
+	return errs
+}
+
+// setVModuleGlobally applies the already validated specs to our loggers.
+// the caller must hold globalSpecLock.
+func setVModuleGlobally(specs []VModuleSpec) {
+	globalVModule.Store(newVModule(specs))
+}
+
+// klogVerbosityFor returns the verbosity that klog must use so that its own checks do not filter out the
+// logs that may be enabled by a vmodule pattern.  our loggers perform the actual checks, see levelCore.
+// this is not free since klog based libraries build every log that klog's checks allow, see LogSpec.VModule.
+func klogVerbosityFor(klogLevel klog.Level) klog.Level {
+	if v := globalVModule.Load(); v != nil && klog.Level(-v.max) > klogLevel {
+		return klog.Level(-v.max) // klog levels are inverted when zap handles them
+	}
+	return klogLevel
+}
+
+// vmodule holds the VModuleSpecs in their internal form.  a nil vmodule matches nothing.
+type vmodule struct {
+	patterns []vmodulePattern
+	max      zapcore.Level // the most verbose level of all patterns
+
+	cache sync.Map // caller PC to vmoduleMatch, which bounds memory use to the number of call sites
+}
+
+type vmodulePattern struct {
+	pattern string
+	pkg     bool // match against the package path instead of the file name
+	level   zapcore.Level
+}
+
+type vmoduleMatch struct {
+	level zapcore.Level
+	ok    bool
+}
+
+func newVModule(specs []VModuleSpec) *vmodule {
+	if len(specs) == 0 {
+		return nil
+	}
+
+	out := &vmodule{max: zapcore.InvalidLevel}
+	for _, s := range specs {
+		level := zapcore.Level(-klogLevelForMlogLevel(s.Level)) // klog levels are inverted when zap handles them
+		out.patterns = append(out.patterns, vmodulePattern{
+			pattern: s.Pattern,
+			pkg:     strings.Contains(s.Pattern, "/"),
+			level:   level,
+		})
+		if level < out.max {
+			out.max = level
+		}
+	}
+	return out
+}
+
+func loadVModule(v *atomic.Pointer[vmodule]) *vmodule {
+	if v == nil {
+		return nil
+	}
+	return v.Load()
+}
+
+// enabled reports if any pattern could enable the level, which is all that can be checked before the caller is known.
+func (v *vmodule) enabled(level zapcore.Level) bool {
+	return v != nil && v.max.Enabled(level)
+}
+
+// enabledFor reports if the first pattern that matches the entry's caller enables the entry.
+func (v *vmodule) enabledFor(ent zapcore.Entry) bool {
+	if v == nil || !ent.Caller.Defined {
+		return false
+	}
+	m := v.match(ent.Caller)
+	return m.ok && m.level.Enabled(ent.Level)
+}
+
+func (v *vmodule) match(caller zapcore.EntryCaller) vmoduleMatch {
+	if cached, ok := v.cache.Load(caller.PC); ok {
+		return cached.(vmoduleMatch)
+	}
+
+	file := strings.TrimSuffix(path.Base(caller.File), ".go")
+	pkg := packagePath(caller.Function)
+
+	var m vmoduleMatch
+	for _, p := range v.patterns {
+		target := file
+		if p.pkg {
+			target = pkg
+		}
+		if ok, _ := path.Match(p.pattern, target); ok {
+			m = vmoduleMatch{level: p.level, ok: true}
+			break
+		}
+	}
+
+	v.cache.Store(caller.PC, m)
+	return m
+}
+
+// packagePath returns the package path of a fully qualified function name,
+// i.e. k8s.io/client-go/tools/leaderelection for k8s.io/client-go/tools/leaderelection.(*LeaderElector).renew.
+func packagePath(function string) string {
+	slash := strings.LastIndexByte(function, '/') + 1 // the package path ends at the first dot after the last slash
+	if dot := strings.IndexByte(function[slash:], '.'); dot != -1 {
+		function = function[:slash+dot]
+	}
+	return strings.ReplaceAll(function, "%2e", ".") // the runtime escapes dots in the last element of the package path
+}
@@ -0,0 +1,125 @@
+package mlog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+func TestVModule(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+		require.NoError(t, Shutdown(context.Background()))
+	})
+
+	logAll := func() []map[string]interface{} {
+		offset := len(buf.Bytes())
+		for i := 0; i < 2; i++ { // the second iteration uses the cached matches
+			Info("info")
+			Debug("debug")
+			Trace("trace")
+			klog.V(klogLevelDebug).InfoS("klog debug")
+		}
+		return jsonLines(t, buf.Bytes()[offset:])
+	}
+	messages := func(lines []map[string]interface{}) []interface{} {
+		var out []interface{}
+		for _, line := range lines {
+			out = append(out, line["message"])
+		}
+		return out
+	}
+
+	// file patterns also apply to klog
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{
+		Level: LevelInfo,
+		VModule: []VModuleSpec{
+			{Pattern: "config", Level: LevelAll},
+			{Pattern: "vmodule_*", Level: LevelDebug},
+			{Pattern: "monis.app/*", Level: LevelTrace}, // the first match wins
+		},
+	}))
+	require.Equal(t, []interface{}{"info", "debug", "klog debug", "info", "debug", "klog debug"}, messages(logAll()))
+	require.False(t, Enabled(LevelDebug), "vmodule does not change the global level")
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{
+		Level:   LevelInfo,
+		VModule: []VModuleSpec{{Pattern: "monis.app/*", Level: LevelTrace}},
+	}))
+	require.Equal(t, []interface{}{"info", "debug", "trace", "klog debug", "info", "debug", "trace", "klog debug"}, messages(logAll()))
+	require.Equal(t, klog.Level(klogLevelTrace), getKlogLevel(), "klog must not filter out logs that may be enabled by vmodule")
+
+	// vmodule can only make logs more verbose
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{
+		Level:   LevelDebug,
+		VModule: []VModuleSpec{{Pattern: "vmodule_test", Level: LevelInfo}, {Pattern: "panda", Level: LevelAll}},
+	}))
+	lines := logAll()
+	require.Equal(t, []interface{}{"info", "debug", "klog debug", "info", "debug", "klog debug"}, messages(lines))
+	require.Contains(t, lines[0]["caller"], "vmodule_test.go:")
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelInfo}))
+	require.Equal(t, []interface{}{"info", "info"}, messages(logAll()))
+	require.Equal(t, klog.Level(klogLevelInfo), getKlogLevel())
+
+	// independent loggers have their own patterns
+	var instanceBuf syncBuffer
+	instance, err := NewWithConfig(LogSpec{VModule: []VModuleSpec{{Pattern: "vmodule_test", Level: LevelDebug}}}, WithWriter(&instanceBuf))
+	require.NoError(t, err)
+	instance.Debug("instance debug")
+	instance.Trace("instance trace")
+	require.NoError(t, instance.Close())
+	require.Equal(t, []interface{}{"instance debug"}, messages(jsonLines(t, instanceBuf.Bytes())))
+}
+
+func TestVModuleValidate(t *testing.T) {
+	spec := LogSpec{VModule: []VModuleSpec{
+		{Pattern: "leader*", Level: LevelDebug},
+		{Pattern: "", Level: LevelDebug},
+		{Pattern: "[", Level: "panda"},
+	}, Format: FormatText}
+
+	var messages []string
+	for _, err := range spec.Validate(field.NewPath("log")) {
+		messages = append(messages, err.Error())
+	}
+	require.Equal(t, []string{
+		`log.vmodule[1].pattern: Required value`,
+		`log.vmodule[2].pattern: Invalid value: "[": syntax error in pattern`,
+		`log.vmodule[2].level: Unsupported value: "panda": supported values: "", "info", "debug", "trace", "all"`,
+		`log.vmodule: Forbidden: the text format is not supported when vmodule is set`,
+	}, messages)
+
+	err := ValidateAndSetLogLevelAndFormatGlobally(context.Background(), spec)
+	require.ErrorIs(t, err, ErrInvalidLogVModule)
+	require.ErrorIs(t, err, ErrInvalidLogLevel)
+}
+
+func TestPackagePath(t *testing.T) {
+	for function, want := range map[string]string{
+		"k8s.io/client-go/tools/leaderelection.(*LeaderElector).renew": "k8s.io/client-go/tools/leaderelection",
+		"monis.app/mlog.TestPackagePath":                               "monis.app/mlog",
+		"monis.app/mlog.TestPackagePath.func1":                         "monis.app/mlog",
+		"main.main":                                                    "main",
+		"gopkg.in/yaml%2ev3.Unmarshal":                                 "gopkg.in/yaml.v3",
+		"":                                                             "",
+	} {
+		require.Equal(t, want, packagePath(function), function)
+	}
+}
@@ -0,0 +1,177 @@
+package mlog
+
+import (
+	"io"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// warningKey marks the logs that are emitted via mLogger.Warning.  klog's structured logging has no concept
+// of a warning (i.e. no WarningS function), so warnings are logged as info at log level zero with this key,
+// which keeps them compatible with klog's logr based routing.  our loggers then replace the key with the
+// warning level, unless LogSpec.LegacyWarningKey is set.
+const warningKey = "warning"
+
+// warningMarker is the value of warningKey.  it is unexported so that callers cannot construct it, i.e. keys
+// and values such as "warning", true that are passed by callers (or by klog) never turn a log into a warning.
+type warningMarker bool
+
+// isWarning reports if the entry was marked as a warning by mLogger.warningDepth, which always adds the marker first.
+func isWarning(ent zapcore.Entry, fields []zapcore.Field) bool {
+	if ent.Level != zapcore.InfoLevel || len(fields) == 0 || fields[0].Key != warningKey {
+		return false
+	}
+	return isWarningMarker(fields[0].Interface)
+}
+
+func isWarningMarker(v interface{}) bool {
+	_, ok := v.(warningMarker)
+	return ok
+}
+
+// warningCoreOption wraps the core in a warningCore.  it must be applied before any other option that wraps
+// the core (except for errorCoreOption) so that the warningCore is the core that is added to the checked entry.
+func warningCoreOption(legacyKey bool) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &warningCore{core: core, legacyKey: legacyKey}
+	})
+}
+
+var _ zapcore.Core = &warningCore{}
+
+// warningCore encodes warnings at zap's warn level, see levelEncoder.  the entry's level is changed in Write
+// instead of Check because the fields are not known until then, which also means that all level checks are
+// performed against level zero, i.e. this does not change which logs are enabled.
+type warningCore struct {
+	core      zapcore.Core
+	legacyKey bool // keep the warning key in addition to the level
+}
+
+func (w *warningCore) Enabled(level zapcore.Level) bool {
+	return w.core.Enabled(level)
+}
+
+func (w *warningCore) With(fields []zapcore.Field) zapcore.Core {
+	return &warningCore{core: w.core.With(fields), legacyKey: w.legacyKey}
+}
+
+func (w *warningCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if w.core.Enabled(ent.Level) {
+		return ce.AddCore(ent, w)
+	}
+	return ce
+}
+
+func (w *warningCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if isWarning(ent, fields) {
+		ent.Level = zapcore.WarnLevel
+		if w.legacyKey {
+			fields = append([]zapcore.Field{zap.Bool(warningKey, true)}, fields[1:]...) // encode the marker as a plain bool
+		} else {
+			fields = fields[1:]
+		}
+	}
+	return w.core.Write(ent, fields)
+}
+
+func (w *warningCore) Sync() error {
+	return w.core.Sync()
+}
+
+// newWarningTextLogger returns a logger that writes warnings with a W header in the text format.  w must be the
+// writer that was passed to the text logger.  the warning key is removed (or moved to the end if legacyKey is set)
+// and the warning is marked on w instead, so that the keys and values passed by callers cannot affect the header.
+func newWarningTextLogger(log logr.Logger, w *warningWriter, legacyKey bool) logr.Logger {
+	sink := log.GetSink()
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(1) // account for the extra frame from warningSink
+	}
+	return logr.New(&warningSink{sink: sink, w: w, legacyKey: legacyKey})
+}
+
+var _ logr.CallDepthLogSink = &warningSink{}
+
+type warningSink struct {
+	sink      logr.LogSink
+	w         *warningWriter
+	legacyKey bool // keep the warning key in addition to the header
+}
+
+func (w *warningSink) Init(logr.RuntimeInfo) {
+	// the wrapped sink was already initialized by its logger and is adjusted for our call depth by newWarningTextLogger
+}
+
+func (w *warningSink) Enabled(level int) bool {
+	return w.sink.Enabled(level)
+}
+
+func (w *warningSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	if level != 0 || len(keysAndValues) < 2 || keysAndValues[0] != warningKey || !isWarningMarker(keysAndValues[1]) {
+		w.w.mu.RLock()
+		defer w.w.mu.RUnlock()
+
+		w.sink.Info(level, msg, keysAndValues...)
+		return
+	}
+
+	keysAndValues = keysAndValues[2:len(keysAndValues):len(keysAndValues)] // appending copies instead of mutating the caller's slice
+	if w.legacyKey {
+		keysAndValues = append(keysAndValues, warningKey, true)
+	}
+
+	w.w.mu.Lock()
+	defer w.w.mu.Unlock()
+
+	w.w.warning = true
+	defer func() { w.w.warning = false }()
+
+	w.sink.Info(level, msg, keysAndValues...)
+}
+
+func (w *warningSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	w.w.mu.RLock()
+	defer w.w.mu.RUnlock()
+
+	w.sink.Error(err, msg, keysAndValues...)
+}
+
+func (w *warningSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &warningSink{sink: w.sink.WithValues(keysAndValues...), w: w.w, legacyKey: w.legacyKey}
+}
+
+func (w *warningSink) WithName(name string) logr.LogSink {
+	return &warningSink{sink: w.sink.WithName(name), w: w.w, legacyKey: w.legacyKey}
+}
+
+func (w *warningSink) WithCallDepth(depth int) logr.LogSink {
+	withCallDepth, ok := w.sink.(logr.CallDepthLogSink)
+	if !ok {
+		return w
+	}
+	return &warningSink{sink: withCallDepth.WithCallDepth(depth), w: w.w, legacyKey: w.legacyKey}
+}
+
+var _ io.Writer = &warningWriter{}
+
+// warningWriter replaces the I header of the text format with a W header for the lines that are written
+// while a warningSink logs a warning.  the text logger writes each line synchronously from the logging call.
+type warningWriter struct {
+	w io.Writer
+
+	mu      sync.RWMutex // held exclusively while a warning is logged so that no other lines are written meanwhile
+	warning bool
+}
+
+func (w *warningWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.warning && len(p) > 0 && p[0] == 'I' {
+		p[0] = 'W'
+	}
+	if _, err := w.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
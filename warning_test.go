@@ -0,0 +1,74 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyWarningKey(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		var jsonBuf syncBuffer
+		jsonLog, err := NewWithConfig(LogSpec{LegacyWarningKey: legacy}, WithWriter(&jsonBuf))
+		require.NoError(t, err)
+
+		jsonLog.Warning("careful", "panda", 1)
+		jsonLog.Always("not a warning")
+		jsonLog.Always("user data", "warning", true, "panda", 2) // must not be confused with the marker
+		require.NoError(t, jsonLog.Close())
+
+		lines := jsonLines(t, jsonBuf.Bytes())
+		require.Len(t, lines, 3)
+		require.Equal(t, "warning", lines[0]["level"])
+		require.Equal(t, float64(1), lines[0]["panda"])
+		if legacy {
+			require.Equal(t, true, lines[0]["warning"])
+		} else {
+			require.NotContains(t, lines[0], "warning")
+		}
+		require.Equal(t, "info", lines[1]["level"])
+		require.NotContains(t, lines[1], "warning")
+		require.Equal(t, "info", lines[2]["level"])
+		require.Equal(t, true, lines[2]["warning"])
+		require.Equal(t, float64(2), lines[2]["panda"])
+	}
+}
+
+func TestWarningText(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		var buf bytes.Buffer
+		ctx := TestZapOverrides(context.Background(), t, &buf, nil)
+		text, _, err := newTextLogr(ctx, 0, logOutput{encoding: "text", legacyWarningKey: legacy})
+		require.NoError(t, err)
+
+		l := mLogger{log: &text.log}
+		l.Warning("careful", "panda", 1)
+		l.Always("not a warning", "panda", 1, "warning", true) // user data must not turn this into a warning
+		l.Always("also not a warning", "warning", true, "panda", 2)
+		l.Error("error", errors.New("oops"), "warning", true)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 4)
+
+		require.True(t, strings.HasPrefix(lines[0], "W"), lines[0])
+		require.Contains(t, lines[0], "warning_test.go:", "the caller must account for the extra frames")
+		if legacy {
+			require.True(t, strings.HasSuffix(lines[0], `"careful" panda=1 warning=true`), lines[0])
+		} else {
+			require.True(t, strings.HasSuffix(lines[0], `"careful" panda=1`), lines[0])
+		}
+
+		require.True(t, strings.HasPrefix(lines[1], "I"), lines[1])
+		require.True(t, strings.HasSuffix(lines[1], `"not a warning" panda=1 warning=true`), lines[1])
+
+		require.True(t, strings.HasPrefix(lines[2], "I"), lines[2])
+		require.True(t, strings.HasSuffix(lines[2], `"also not a warning" warning=true panda=2`), lines[2])
+
+		require.True(t, strings.HasPrefix(lines[3], "E"), lines[3])
+		require.True(t, strings.HasSuffix(lines[3], `err="oops" warning=true`), lines[3])
+	}
+}
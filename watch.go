@@ -0,0 +1,190 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configFilePollInterval is how often the config file is re-read when filesystem notifications are unavailable.
+const configFilePollInterval = 5 * time.Second
+
+// WatchConfigFile re-reads the config file at path whenever its contents change or the process receives
+// a SIGHUP.  Changes are detected via filesystem notifications on the parent directory of path, which also
+// catches atomic replacements such as the symlink swap used for mounted ConfigMaps.  If notifications are
+// unavailable, the file is polled instead.  extract is used to parse the LogSpec out of the file, which allows
+// the log config to be embedded in a larger config file.  Valid changes are applied globally via
+// ValidateAndSetLogLevelAndFormatGlobally while invalid changes are logged and ignored, i.e. the previous
+// config remains active.  The initial contents of the file are assumed to already be applied.
+// WatchConfigFile blocks until ctx is done.  Cancelling ctx stops the watch but not the background flushing of
+// the reloaded config, see ReloadLogSpecGlobally.
+func WatchConfigFile(ctx context.Context, path string, extract func([]byte) (LogSpec, error)) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	changes, err := configFileChanges(ctx, path)
+	if err != nil {
+		WarningErr("could not watch log config file, falling back to polling", err, "path", path, "interval", configFilePollInterval)
+	}
+
+	watchConfigFile(ctx, path, extract, changes, configFilePollInterval, sighup)
+}
+
+// watchConfigFile re-reads path whenever changes receives a value.  It polls path every interval
+// instead if changes is nil or once it is closed.
+func watchConfigFile(ctx context.Context, path string, extract func([]byte) (LogSpec, error), changes <-chan struct{}, interval time.Duration, sighup <-chan os.Signal) {
+	last, err := os.ReadFile(path)
+	if err != nil {
+		Error("could not read log config file", err, "path", path)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		force := false
+
+		var poll <-chan time.Time
+		if changes == nil {
+			poll = ticker.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil // the watch failed, see configFileChanges
+				continue
+			}
+		case <-poll:
+		case <-sighup:
+			force = true
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			Error("could not read log config file", err, "path", path)
+			continue
+		}
+
+		if !force && bytes.Equal(last, data) {
+			continue
+		}
+		last = data
+
+		reloadConfig(ctx, path, data, extract)
+	}
+}
+
+// configFileChanges returns a channel that receives a value whenever an entry in the parent directory of path
+// changes.  The directory is watched instead of the file itself because a watch on the file is lost when the
+// file is atomically replaced.  The channel is closed when ctx is done or when the watch fails.
+func configFileChanges(ctx context.Context, path string) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		defer func() { _ = watcher.Close() }()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				select {
+				case changes <- struct{}{}:
+				default: // a change is already pending and the whole file is re-read anyway
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				Error("could not watch log config file, falling back to polling", err, "path", path)
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+func reloadConfig(ctx context.Context, path string, data []byte, extract func([]byte) (LogSpec, error)) {
+	spec, err := extract(data)
+	if err != nil {
+		Error("could not parse log config file", err, "path", path)
+		return
+	}
+
+	old, updated, err := ReloadLogSpecGlobally(ctx, spec)
+	if err != nil {
+		Error("rejected invalid log config file, previous config remains active", err, "path", path, "old", old, "new", spec)
+		return
+	}
+	if !reflect.DeepEqual(old, updated) {
+		Always("log config reloaded from file", "old", old, "new", updated, "path", path)
+	}
+}
+
+// ReloadLogSpecGlobally is ValidateAndSetLogLevelAndFormatGlobally for config reloads, i.e. spec is only applied
+// if it differs from the configured LogSpec, see ConfiguredSpec.  It returns the configured LogSpec from before
+// and after the reload, which are equal if nothing was applied.  If spec is invalid, the configured LogSpec is
+// left unchanged and returned as old along with the error.  Unlike ValidateAndSetLogLevelAndFormatGlobally, only
+// the values of ctx are used: cancelling ctx (i.e. stopping a config watch) does not stop the background flushing
+// of the reloaded config, which continues until Shutdown.
+func ReloadLogSpecGlobally(ctx context.Context, spec LogSpec) (old, updated LogSpec, err error) {
+	old = ConfiguredSpec()
+
+	// compare against the normalized form that is stored when a spec is applied
+	normalized := spec.deepCopy()
+	if len(normalized.Format) == 0 {
+		normalized.Format = FormatJSON
+	}
+	if reflect.DeepEqual(old, normalized) {
+		return old, old, nil
+	}
+
+	if err := ValidateAndSetLogLevelAndFormatGlobally(detachedContext{ctx}, spec); err != nil {
+		return old, old, err
+	}
+
+	return old, ConfiguredSpec(), nil
+}
+
+var _ context.Context = detachedContext{}
+
+// detachedContext keeps the values of the wrapped context but is never cancelled, see ReloadLogSpecGlobally.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,148 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/klog/v2"
+)
+
+func TestWatchConfigFile(t *testing.T) {
+	originalLogLevel := getKlogLevel()
+	require.GreaterOrEqual(t, int(originalLogLevel), int(klog.Level(0)), "cannot get klog level")
+	t.Cleanup(func() {
+		undoGlobalLogLevelChanges(t, originalLogLevel)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var buf syncBuffer
+	ctx = TestZapOverrides(ctx, t, &buf, nil)
+
+	require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(ctx, LogSpec{Level: LevelInfo}))
+	t.Cleanup(func() {
+		require.NoError(t, ValidateAndSetLogLevelAndFormatGlobally(context.Background(), LogSpec{}))
+	})
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFile := func(data string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	}
+	writeFile(`{"log":{"level":"info"}}`)
+
+	extract := func(data []byte) (LogSpec, error) {
+		var config struct {
+			Log LogSpec `json:"log"`
+		}
+		err := json.Unmarshal(data, &config)
+		return config.Log, err
+	}
+
+	watch := func(path string, notify bool, interval time.Duration) (chan<- os.Signal, func()) {
+		t.Helper()
+
+		watchCtx, watchCancel := context.WithCancel(ctx)
+
+		var changes <-chan struct{}
+		if notify {
+			var err error
+			changes, err = configFileChanges(watchCtx, path)
+			require.NoError(t, err)
+		}
+
+		sighup := make(chan os.Signal)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			watchConfigFile(watchCtx, path, extract, changes, interval, sighup)
+		}()
+		sighup <- os.Interrupt // wait for the initial read of the file, this is a no-op since the config is unchanged
+
+		return sighup, func() {
+			watchCancel()
+			<-done
+		}
+	}
+
+	// filesystem notifications are used when available, i.e. the file is never polled
+	_, stop := watch(path, true, time.Hour)
+
+	writeFile(`{"log":{"level":"debug"}}`)
+	require.Eventually(t, func() bool {
+		return bytes.Contains(buf.Bytes(), []byte(`"message":"log config reloaded from file"`))
+	}, time.Minute, 10*time.Millisecond)
+	require.Equal(t, LogSpec{Level: LevelDebug, Format: FormatJSON}, CurrentSpec())
+	require.Equal(t, klog.Level(4), getKlogLevel())
+
+	writeFile(`{"log":{"file":{}}}`)
+	require.Eventually(t, func() bool {
+		return bytes.Contains(buf.Bytes(), []byte(`"message":"rejected invalid log config file, previous config remains active"`))
+	}, time.Minute, 10*time.Millisecond)
+	require.Equal(t, LogSpec{Level: LevelDebug, Format: FormatJSON}, CurrentSpec())
+	require.Equal(t, klog.Level(4), getKlogLevel())
+
+	stop()
+
+	// stopping the watch does not stop the background flushing of the reloaded config
+	globalSpecLock.Lock()
+	l := globalLifecycle
+	globalSpecLock.Unlock()
+	require.NotNil(t, l)
+	select {
+	case <-l.done:
+		t.Fatal("the lifecycle of the reloaded config must not depend on the watch")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// polling is the fallback when filesystem notifications are unavailable
+	_, stop = watch(path, false, 10*time.Millisecond)
+
+	writeFile(`{"log":{"format":"cli"}}`)
+	require.Eventually(t, func() bool {
+		return bytes.Contains(buf.Bytes(), []byte(`"message":"could not parse log config file"`))
+	}, time.Minute, 10*time.Millisecond)
+	require.Equal(t, LogSpec{Level: LevelDebug, Format: FormatJSON}, CurrentSpec())
+
+	stop()
+
+	// a SIGHUP forces a re-read even if the file has not been polled yet
+	sighup, stop := watch(path, false, time.Hour)
+	writeFile(`{"log":{"level":"trace"}}`)
+	sighup <- os.Interrupt
+	sighup <- os.Interrupt // wait for the first signal to be fully processed
+	require.Equal(t, LogSpec{Level: LevelTrace, Format: FormatJSON}, CurrentSpec())
+	require.Equal(t, klog.Level(6), getKlogLevel())
+
+	stop()
+
+	// mounted ConfigMaps are updated by atomically swapping the ..data symlink to a new directory
+	dir := t.TempDir()
+	writeVersion := func(version, data string) {
+		t.Helper()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, version, "config.json"), []byte(data), 0o600))
+		require.NoError(t, os.Symlink(version, filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	}
+	writeVersion("..v1", `{"log":{"level":"trace"}}`)
+	require.NoError(t, os.Symlink(filepath.Join("..data", "config.json"), filepath.Join(dir, "config.json")))
+
+	_, stop = watch(filepath.Join(dir, "config.json"), true, time.Hour)
+
+	writeVersion("..v2", `{"log":{"level":"info"}}`)
+	require.Eventually(t, func() bool {
+		return CurrentSpec().Level == LevelInfo
+	}, time.Minute, 10*time.Millisecond)
+	require.Equal(t, klog.Level(2), getKlogLevel())
+
+	stop()
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -23,28 +25,89 @@ import (
 	"k8s.io/klog/v2/textlogger"
 )
 
-func newLogr(ctx context.Context, encoding string, klogLevel klog.Level) (logr.Logger, func(), error) {
-	if encoding == "text" {
-		var w io.Writer = os.Stderr
-		flush := func() { _ = os.Stderr.Sync() }
+// logOutput is a single destination for logs, see OutputSpec.
+type logOutput struct {
+	encoding         string
+	level            zapcore.LevelEnabler // optional, further restricts the global level
+	file             *FileSpec            // optional, logs go to stderr if unset
+	errors           *ErrorSpec           // optional, see LogSpec.Errors
+	stacktrace       StacktracePolicy     // see LogSpec.Stacktrace
+	stackFilter      *StackFilterSpec     // optional, see LogSpec.StackFilter
+	legacyWarningKey bool                 // see LogSpec.LegacyWarningKey
+}
 
-		// allow tests to override klog config (but cheat and re-use the zap override key)
-		if overrides, ok := ctx.Value(zapOverridesKey).(*testOverrides); ok {
-			if overrides.w != nil {
-				w = newSink(overrides.w) // make sure the value is safe for concurrent use
-				flush = func() {}
-			}
-		}
+// stacktraces reports if the output includes stack traces.
+func (o logOutput) stacktraces() bool {
+	return o.encoding == "json" || o.stacktrace.allFormats()
+}
 
-		w = &trimWriter{w: w}
+// zapLevels are the levels checked by a zap based logger.  they are the global levels unless
+// the logger has its own config, see NewWithConfig.
+type zapLevels struct {
+	level    zap.AtomicLevel
+	levels   *atomic.Pointer[loggerLevels]
+	vmodule  *atomic.Pointer[vmodule]
+	addStack zapcore.LevelEnabler // when logs include stack traces, nil means never
+	trace    zapcore.LevelEnabler // when the log level is trace or all, regardless of the entry's level
+}
 
-		return textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(int(klogLevel)), textlogger.Output(w))), flush, nil
+func globalZapLevels(stacktrace StacktracePolicy) zapLevels {
+	// by default, when using the trace or all log levels, an error log will contain the full stack.
+	// this is too noisy for regular use because things like leader election conflicts
+	// result in transient errors and we do not want all of that noise in the logs.
+	// this check is performed dynamically on the global log level.
+	addStack := stacktrace.enabler(func(level LogLevel) zapcore.LevelEnabler { return level })
+	return zapLevels{level: globalLevel, levels: &globalLoggerLevels, vmodule: &globalVModule, addStack: addStack, trace: LevelTrace}
+}
+
+// sampler is optional and is ignored by the text format.
+func newLogr(ctx context.Context, klogLevel klog.Level, sampler *sampler, outputs ...logOutput) (*loggers, error) {
+	if len(outputs) == 1 && outputs[0].encoding == "text" {
+		l, _, err := newTextLogr(ctx, klogLevel, outputs[0])
+		return l, err
 	}
 
-	path := "stderr" // this is how zap refers to os.Stderr
+	return newOutputsLogr(ctx, globalZapLevels(outputs[0].stacktrace), nil, sampler, true, outputs...)
+}
+
+// newOutputsLogr builds a zap based logger for the outputs, which must not use the text encoding.
+// outputs that do not log to a file use w if it is set, and stderr otherwise.  global is used to
+// determine if the rotation config of files that are already in use may be changed, see acquireRotatingFile.
+func newOutputsLogr(ctx context.Context, levels zapLevels, w io.Writer, sampler *sampler, global bool, outputs ...logOutput) (_ *loggers, err error) {
+	errPath := "stderr" // zap's internal errors always go to stderr so that they are visible even if the log file is not
+	if w != nil {
+		var unregister func()
+		errPath, unregister = registerWriterSink(w)
+		defer unregister() // zap only opens sinks while building the logger
+	}
+	var files []zap.Sink
+	release := func() error { return closeSinks(files) }
+	defer func() {
+		if err != nil {
+			_ = release() // the files are not used by any logger
+		}
+	}()
+	zapOutputs := make([]zapOutput, 0, len(outputs))
+	for _, output := range outputs {
+		path := stderrSinkURL
+		if w != nil {
+			path = errPath
+		}
+		if output.file != nil {
+			file, err := openRotatingFile(output.file, global)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+			var unregister func()
+			path, unregister = registerWriterSink(file)
+			defer unregister()
+		}
+		zapOutputs = append(zapOutputs, zapOutput{encoding: output.encoding, level: output.level, path: path, errors: output.errors, stacktraces: output.stacktraces(), stackFilter: output.stackFilter, legacyWarningKey: output.legacyWarningKey})
+	}
 	f := func(config *zap.Config) {
-		if encoding == "console" {
-			config.EncoderConfig.LevelKey = zapcore.OmitKey
+		if config.Encoding == "console" {
+			config.EncoderConfig.EncodeLevel = cliLevelEncoder
 			config.EncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
 			config.EncoderConfig.EncodeTime = humanTimeEncoder
 			config.EncoderConfig.EncodeDuration = humanDurationEncoder
@@ -58,8 +121,14 @@ func newLogr(ctx context.Context, encoding string, klogLevel klog.Level) (logr.L
 			// use a per invocation random string as the key into the global map
 			testKey := "/" + base64.RawURLEncoding.EncodeToString([]byte(rand.String(32)))
 
-			// tell zap to use our custom sink registry to find the writer
-			path = "monis.app-mlog://" + testKey
+			// tell zap to use our custom sink registry to find the writer for everything that would go to stderr
+			testPath := "monis.app-mlog://" + testKey
+			errPath = testPath
+			for i := range zapOutputs {
+				if outputs[i].file == nil {
+					zapOutputs[i].path = testPath
+				}
+			}
 
 			// the registry may be called multiple times so make sure the value is safe for concurrent use
 			sink := newSink(overrides.w)
@@ -84,29 +153,122 @@ func newLogr(ctx context.Context, encoding string, klogLevel klog.Level) (logr.L
 		}
 	}
 
-	// when using the trace or all log levels, an error log will contain the full stack.
-	// this is too noisy for regular use because things like leader election conflicts
-	// result in transient errors and we do not want all of that noise in the logs.
-	// this check is performed dynamically on the global log level.
-	return newZapr(globalLevel, LevelTrace, encoding, path, f, opts...)
+	log, flush, err := newZapr(levels, sampler, zapOutputs, errPath, f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &loggers{log: log, flush: flush, release: release}, nil
+}
+
+// registerWriterSink makes w available to zap via the returned path until unregister is called.
+func registerWriterSink(w io.Writer) (path string, unregister func()) {
+	// use a per invocation random string as the key into the global map, see the init function
+	key := "/" + base64.RawURLEncoding.EncodeToString([]byte(rand.String(32)))
+	sinkMap.Store(key, newSink(w)) // the registry may be called multiple times so make sure the value is safe for concurrent use
+	return "monis.app-mlog://" + key, func() { sinkMap.Delete(key) }
+}
+
+// newTextLogr builds a klog based logger for the output, which must use the text encoding.
+func newTextLogr(ctx context.Context, klogLevel klog.Level, output logOutput) (*loggers, *textlogger.Config, error) {
+	var w io.Writer = os.Stderr
+	flush := syncStderr
+	release := func() error { return nil }
+
+	if output.file != nil {
+		// share the rotation logic with the other formats
+		file, err := openRotatingFile(output.file, true) // only the global config uses the text format
+		if err != nil {
+			return nil, nil, err
+		}
+		w = file
+		flush = file.Sync
+		release = file.Close
+	}
+
+	// allow tests to override klog config (but cheat and re-use the zap override key)
+	if overrides, ok := ctx.Value(zapOverridesKey).(*testOverrides); ok {
+		if overrides.w != nil {
+			w = newSink(overrides.w) // make sure the value is safe for concurrent use
+			flush = func() error { return nil }
+		}
+	}
+
+	warnings := &warningWriter{w: &trimWriter{w: w}}
+
+	// the config is returned so that the verbosity can be changed without rebuilding the logger
+	config := textlogger.NewConfig(textlogger.Verbosity(int(klogLevel)), textlogger.Output(warnings))
+
+	log := newErrorTextLogger(newWarningTextLogger(textlogger.NewLogger(config), warnings, output.legacyWarningKey), output.errors)
+	log = newStackTextLogger(log, output.stacktrace, output.stackFilter)
+	return &loggers{log: log, flush: flush, release: release}, config, nil
 }
 
-func newZapr(level zap.AtomicLevel, addStack zapcore.LevelEnabler, encoding, path string, f func(config *zap.Config), opts ...zap.Option) (logr.Logger, func(), error) {
+type zapOutput struct {
+	encoding         string
+	level            zapcore.LevelEnabler
+	path             string
+	errors           *ErrorSpec
+	stacktraces      bool
+	stackFilter      *StackFilterSpec
+	legacyWarningKey bool
+}
+
+func newZapr(levels zapLevels, sampler *sampler, outputs []zapOutput, errPath string, f func(config *zap.Config), opts ...zap.Option) (logr.Logger, func() error, error) {
 	opts = append([]zap.Option{zap.WrapCore(func(core zapcore.Core) zapcore.Core {
 		return &trimCore{core: core}
 	})}, opts...)
 
-	if encoding == "json" { // stack traces are too noisy otherwise
-		opts = append([]zap.Option{zap.AddStacktrace(addStack)}, opts...)
+	for _, output := range outputs {
+		if output.stacktraces && levels.addStack != nil {
+			opts = append([]zap.Option{zap.AddStacktrace(levels.addStack)}, opts...)
+			break
+		}
+	}
+
+	config, configLevel := newZapConfig(levels.level, outputs[0], errPath, f)
+
+	// sample once before the tee so that an entry is either written to all outputs or dropped
+	if sampler != nil {
+		opts = append([]zap.Option{zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &samplingCore{core: core, level: configLevel, levels: levels.levels, vmodule: levels.vmodule, sampler: sampler}
+		})}, opts...)
 	}
 
+	// the first output is used to build the logger and all other outputs are teed into its core
+	var cores []zapcore.Core
+	for _, output := range outputs[1:] {
+		config, configLevel := newZapConfig(levels.level, output, errPath, f)
+		log, err := config.Build(errorCoreOption(output.errors, levels.trace), stackCoreOption(output.stackFilter), warningCoreOption(output.legacyWarningKey), levelCoreOption(configLevel, levels, output.level))
+		if err != nil {
+			return logr.Logger{}, nil, fmt.Errorf("failed to build zap logger: %w", err)
+		}
+		cores = append(cores, log.Core())
+	}
+	if len(cores) > 0 {
+		opts = append([]zap.Option{zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(append([]zapcore.Core{core}, cores...)...)
+		})}, opts...)
+	}
+
+	opts = append([]zap.Option{errorCoreOption(outputs[0].errors, levels.trace), stackCoreOption(outputs[0].stackFilter), warningCoreOption(outputs[0].legacyWarningKey), levelCoreOption(configLevel, levels, outputs[0].level)}, opts...)
+
+	log, err := config.Build(opts...)
+	if err != nil {
+		return logr.Logger{}, nil, fmt.Errorf("failed to build zap logger: %w", err)
+	}
+
+	return zapr.NewLogger(log), log.Sync, nil
+}
+
+// newZapConfig returns the zap config for a single output along with the level that should be passed to levelCoreOption.
+func newZapConfig(level zap.AtomicLevel, output zapOutput, errPath string, f func(config *zap.Config)) (zap.Config, zap.AtomicLevel) {
 	config := zap.Config{
 		Level:             level,
 		Development:       false,
 		DisableCaller:     false,
-		DisableStacktrace: true, // handled via the AddStacktrace call above
-		Sampling:          nil,  // keep all logs for now
-		Encoding:          encoding,
+		DisableStacktrace: true, // handled via the AddStacktrace call in newZapr
+		Sampling:          nil,  // handled via samplingCore, zap's sampler does not support klog levels
+		Encoding:          output.encoding,
 		EncoderConfig: zapcore.EncoderConfig{
 			MessageKey:     "message",
 			LevelKey:       "level",
@@ -114,7 +276,7 @@ func newZapr(level zap.AtomicLevel, addStack zapcore.LevelEnabler, encoding, pat
 			NameKey:        "logger",
 			CallerKey:      "caller",
 			FunctionKey:    zapcore.OmitKey, // included in caller
-			StacktraceKey:  "stacktrace",
+			StacktraceKey:  stacktraceKey,
 			SkipLineEnding: false,
 			LineEnding:     zapcore.DefaultLineEnding,
 			EncodeLevel:    levelEncoder,
@@ -126,22 +288,39 @@ func newZapr(level zap.AtomicLevel, addStack zapcore.LevelEnabler, encoding, pat
 			NewReflectedEncoder: nil,
 			ConsoleSeparator:    "  ",
 		},
-		OutputPaths:      []string{path},
-		ErrorOutputPaths: []string{path},
+		OutputPaths:      []string{output.path},
+		ErrorOutputPaths: []string{errPath},
 		InitialFields:    nil,
 	}
 
 	f(&config)
 
-	log, err := config.Build(opts...)
-	if err != nil {
-		return logr.Logger{}, nil, fmt.Errorf("failed to build zap logger: %w", err)
+	if !output.stacktraces {
+		config.EncoderConfig.StacktraceKey = zapcore.OmitKey // stack traces are too noisy, even if another output includes them
 	}
 
-	return zapr.NewLogger(log), func() { _ = log.Sync() }, nil
+	// per logger level overrides may be more verbose than the configured level,
+	// so all level checks are performed by levelCore instead of the underlying core.
+	level = config.Level
+	config.Level = zap.NewAtomicLevelAt(math.MinInt8)
+
+	return config, level
+}
+
+// levelCoreOption wraps the core in a levelCore, which only logs entries that are enabled by the
+// level (or the logger's override or a vmodule pattern) and the output's level.
+func levelCoreOption(level zap.AtomicLevel, levels zapLevels, output zapcore.LevelEnabler) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{core: core, level: level, levels: levels.levels, vmodule: levels.vmodule, output: output}
+	})
 }
 
 func levelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if l == zapcore.WarnLevel {
+		enc.AppendString(warningKey) // see warningCore
+		return
+	}
+
 	mlogLevel := zapLevelToMlogLevel(l)
 
 	if len(mlogLevel) == 0 {
@@ -151,6 +330,13 @@ func levelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(string(mlogLevel))
 }
 
+// cliLevelEncoder only encodes warnings because the CLI does not include the level otherwise.
+func cliLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if l == zapcore.WarnLevel {
+		enc.AppendString(warningKey) // see warningCore
+	}
+}
+
 func zapLevelToMlogLevel(l zapcore.Level) LogLevel {
 	if l > 0 {
 		// best effort mapping, the zap levels do not really translate to klog
@@ -227,6 +413,78 @@ func (t *trimCore) Sync() error {
 	return t.core.Sync()
 }
 
+var _ zapcore.Core = &levelCore{}
+
+type levelCore struct {
+	core    zapcore.Core
+	level   zapcore.LevelEnabler
+	levels  *atomic.Pointer[loggerLevels]
+	vmodule *atomic.Pointer[vmodule] // optional, see LogSpec.VModule
+	output  zapcore.LevelEnabler     // optional, see OutputSpec.Level
+}
+
+func (l *levelCore) Enabled(level zapcore.Level) bool {
+	// we do not know the logger name or the caller here so check if any level could be enabled
+	return (l.level.Enabled(level) || l.overrides().enabled(level) || loadVModule(l.vmodule).enabled(level)) && l.outputEnabled(level)
+}
+
+func (l *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{core: l.core.With(fields), level: l.level, levels: l.levels, vmodule: l.vmodule, output: l.output}
+}
+
+func (l *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !l.outputEnabled(ent.Level) {
+		return ce
+	}
+
+	if enabledFor(l.level, l.levels, ent) {
+		return l.core.Check(ent, ce)
+	}
+
+	// zap only collects the caller after the entry has been checked, so defer the vmodule check to Write
+	if loadVModule(l.vmodule).enabled(ent.Level) {
+		return ce.AddCore(ent, l)
+	}
+
+	return ce
+}
+
+func (l *levelCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	// this is only reached for entries that may be enabled by a vmodule pattern, see Check
+	if !loadVModule(l.vmodule).enabledFor(ent) {
+		return nil
+	}
+
+	return l.core.Write(ent, fields)
+}
+
+func (l *levelCore) Sync() error {
+	return l.core.Sync()
+}
+
+func (l *levelCore) outputEnabled(level zapcore.Level) bool {
+	return l.output == nil || l.output.Enabled(level)
+}
+
+func (l *levelCore) overrides() loggerLevels {
+	return loadLoggerLevels(l.levels)
+}
+
+// enabledFor reports if the entry is enabled by the most specific override for its logger, or the configured level if there is none.
+func enabledFor(level zapcore.LevelEnabler, levels *atomic.Pointer[loggerLevels], ent zapcore.Entry) bool {
+	if override, ok := loadLoggerLevels(levels).levelFor(ent.LoggerName); ok {
+		return override.Enabled(ent.Level)
+	}
+	return level.Enabled(ent.Level)
+}
+
+func loadLoggerLevels(levels *atomic.Pointer[loggerLevels]) loggerLevels {
+	if l := levels.Load(); l != nil {
+		return *l
+	}
+	return nil
+}
+
 var _ io.Writer = &trimWriter{}
 
 type trimWriter struct {